@@ -1,16 +1,58 @@
 package main
 
 import (
+	"context"
+	"log"
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/contrib/propagators/aws/xray"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 func main() {
+	ctx := context.Background()
+
 	outputValue := os.Getenv("ENV1")
 	engine := gin.Default()
+
+	// TRACE_ENABLED is set by the stack only when the observability configuration collects the traces,
+	// otherwise there is no tracing sidecar to export them to.
+	if os.Getenv("TRACE_ENABLED") == "true" {
+		tracerProvider, err := newTracerProvider(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer tracerProvider.Shutdown(ctx)
+
+		engine.Use(otelgin.Middleware("go-managed-apprunner"))
+	}
+
 	engine.GET("/", func(c *gin.Context) {
 		c.String(200, outputValue)
 	})
 	engine.Run(":8080")
 }
+
+// The App Runner tracing sidecar receives OTLP on localhost:4317 and forwards it to X-Ray,
+// so trace IDs and propagation headers have to be in the X-Ray format.
+func newTracerProvider(ctx context.Context) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracegrpc.New(ctx, otlptracegrpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithSampler(sdktrace.AlwaysSample()),
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithIDGenerator(xray.NewIDGenerator()),
+	)
+
+	otel.SetTracerProvider(tracerProvider)
+	otel.SetTextMapPropagator(xray.Propagator{})
+
+	return tracerProvider, nil
+}
@@ -0,0 +1,108 @@
+// Package apprunneryaml generates the App Runner configuration file (apprunner.yaml)
+// used by the REPOSITORY configuration source from SourceConfigurationProps.
+package apprunneryaml
+
+import (
+	"bytes"
+	"fmt"
+	"go-cdk-go-managed-apprunner/cdk/input"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultPath is the path of apprunner.yaml at the repository root, relative to the cdk directory.
+const DefaultPath = "../apprunner.yaml"
+
+// Path returns the path of apprunner.yaml in sourceDirectory, which App Runner reads the file from.
+func Path(sourceDirectory string) string {
+	if sourceDirectory == "" {
+		return DefaultPath
+	}
+	return path.Join("..", sourceDirectory, "apprunner.yaml")
+}
+
+const header = "# Generated from cdk/input/input.go by `make apprunner-yaml` in cdk. DO NOT EDIT.\n"
+
+// Generate returns apprunner.yaml of the runtime and the commands resolved from sourceProps.
+// With traceEnabled, TRACE_ENABLED is set to the environment variables like the API configuration source,
+// otherwise the app does not export the traces.
+func Generate(sourceProps *input.SourceConfigurationProps, traceEnabled bool) ([]byte, error) {
+	sourceProps, err := sourceProps.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	runtime, err := input.LookupRuntime(sourceProps.Runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+
+	b.WriteString(header)
+	b.WriteString("version: 1.0\n")
+	fmt.Fprintf(&b, "runtime: %s\n", runtime.ConfigFileRuntime)
+
+	b.WriteString("build:\n")
+	b.WriteString("  commands:\n")
+	b.WriteString("    build:\n")
+	fmt.Fprintf(&b, "      - %s\n", strconv.Quote(sourceProps.BuildCommand))
+
+	b.WriteString("run:\n")
+	fmt.Fprintf(&b, "  command: %s\n", strconv.Quote(sourceProps.StartCommand))
+	b.WriteString("  network:\n")
+	fmt.Fprintf(&b, "    port: %d\n", sourceProps.Port)
+
+	environment := map[string]string{}
+	if traceEnabled {
+		environment["TRACE_ENABLED"] = "true"
+	}
+	for key, value := range sourceProps.Environment {
+		environment[key] = value
+	}
+	if len(environment) > 0 {
+		keys := make([]string, 0, len(environment))
+		for key := range environment {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		b.WriteString("  env:\n")
+		for _, key := range keys {
+			fmt.Fprintf(&b, "    - name: %s\n", strconv.Quote(key))
+			fmt.Fprintf(&b, "      value: %s\n", strconv.Quote(environment[key]))
+		}
+	}
+
+	return b.Bytes(), nil
+}
+
+func Write(filePath string, sourceProps *input.SourceConfigurationProps, traceEnabled bool) error {
+	generated, err := Generate(sourceProps, traceEnabled)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, generated, 0644)
+}
+
+// Check returns an error if the committed file at filePath differs from the one generated from sourceProps.
+func Check(filePath string, sourceProps *input.SourceConfigurationProps, traceEnabled bool) error {
+	committed, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("apprunner.yaml Read Error: %v (run `make apprunner-yaml` in cdk)", err)
+	}
+
+	generated, err := Generate(sourceProps, traceEnabled)
+	if err != nil {
+		return err
+	}
+
+	committed = []byte(strings.ReplaceAll(string(committed), "\r\n", "\n"))
+	if !bytes.Equal(committed, generated) {
+		return fmt.Errorf("%s does not match SourceConfigurationProps; run `make apprunner-yaml` in cdk and commit the file", filePath)
+	}
+
+	return nil
+}
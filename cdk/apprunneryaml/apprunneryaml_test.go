@@ -0,0 +1,160 @@
+package apprunneryaml
+
+import (
+	"go-cdk-go-managed-apprunner/cdk/input"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	sourceProps := &input.SourceConfigurationProps{
+		BuildCommand: "go install ./app/...",
+		StartCommand: "go run app/main.go",
+		Port:         8080,
+		Environment: map[string]string{
+			"ENV2": "value: 2",
+			"ENV1": "value1",
+		},
+	}
+
+	want := header +
+		"version: 1.0\n" +
+		"runtime: go1\n" +
+		"build:\n" +
+		"  commands:\n" +
+		"    build:\n" +
+		"      - \"go install ./app/...\"\n" +
+		"run:\n" +
+		"  command: \"go run app/main.go\"\n" +
+		"  network:\n" +
+		"    port: 8080\n" +
+		"  env:\n" +
+		"    - name: \"ENV1\"\n" +
+		"      value: \"value1\"\n" +
+		"    - name: \"ENV2\"\n" +
+		"      value: \"value: 2\"\n"
+
+	got, err := Generate(sourceProps, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("Generate() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGenerateTraceEnabled(t *testing.T) {
+	sourceProps := &input.SourceConfigurationProps{
+		Port:        8080,
+		Environment: map[string]string{"ENV1": "value1"},
+	}
+
+	got, err := Generate(sourceProps, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "  env:\n" +
+		"    - name: \"ENV1\"\n" +
+		"      value: \"value1\"\n" +
+		"    - name: \"TRACE_ENABLED\"\n" +
+		"      value: \"true\"\n"
+	if !strings.HasSuffix(string(got), want) {
+		t.Errorf("Generate() does not end with\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestGenerateRuntimes(t *testing.T) {
+	cases := []struct {
+		runtime     string
+		wantRuntime string
+		wantBuild   string
+		wantStart   string
+		wantErr     bool
+	}{
+		{runtime: "", wantRuntime: "go1", wantBuild: "go build -o main .", wantStart: "./main"},
+		{runtime: input.RuntimeCorretto11, wantRuntime: "corretto11", wantBuild: "mvn clean package", wantStart: "java -jar target/app.jar"},
+		{runtime: input.RuntimeNodejs18, wantRuntime: "nodejs18", wantBuild: "npm install", wantStart: "npm start"},
+		{runtime: input.RuntimePhp81, wantRuntime: "php81", wantBuild: "composer install --no-dev", wantStart: "php -S 0.0.0.0:3000"},
+		{runtime: input.RuntimePython311, wantRuntime: "python311", wantBuild: "pip3 install -r requirements.txt", wantStart: "python3 app.py"},
+		{runtime: "GO_2", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.runtime, func(t *testing.T) {
+			got, err := Generate(&input.SourceConfigurationProps{Runtime: tc.runtime, Port: 3000}, false)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, but got\n%s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, want := range []string{
+				"runtime: " + tc.wantRuntime + "\n",
+				"      - " + strconv.Quote(tc.wantBuild) + "\n",
+				"  command: " + strconv.Quote(tc.wantStart) + "\n",
+			} {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("Generate() does not contain %q:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateInvalidPort(t *testing.T) {
+	for _, port := range []int{0, -1, 65536} {
+		if _, err := Generate(&input.SourceConfigurationProps{Port: port}, false); err == nil {
+			t.Errorf("Generate() with Port %d: expected an error", port)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	cases := map[string]string{
+		"":              "../apprunner.yaml",
+		"app":           "../app/apprunner.yaml",
+		"services/api":  "../services/api/apprunner.yaml",
+		"services/api/": "../services/api/apprunner.yaml",
+	}
+
+	for sourceDirectory, want := range cases {
+		if got := Path(sourceDirectory); got != want {
+			t.Errorf("Path(%q) = %s, want %s", sourceDirectory, got, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	sourceProps := input.NewAppRunnerStackInputProps().SourceConfigurationProps
+	path := filepath.Join(t.TempDir(), "apprunner.yaml")
+
+	if err := Check(path, sourceProps, true); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+
+	if err := Write(path, sourceProps, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(path, sourceProps, true); err != nil {
+		t.Errorf("expected no error for a generated file, but got %v", err)
+	}
+
+	// The file generated without TRACE_ENABLED does not export the traces of the ObservabilityConfiguration.
+	if err := Check(path, sourceProps, false); err == nil {
+		t.Error("expected an error for a file without TRACE_ENABLED")
+	}
+
+	if err := os.WriteFile(path, []byte("version: 1.0\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(path, sourceProps, true); err == nil {
+		t.Error("expected an error for a modified file")
+	}
+}
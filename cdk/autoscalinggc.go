@@ -0,0 +1,80 @@
+package main
+
+import (
+	"go-cdk-go-managed-apprunner/cdk/input"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsscheduler"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// createAutoScalingGarbageCollection schedules custom/autoscalinggc, which deletes the orphaned AutoScalingConfigurations of the account.
+// The Lambda can delete any AutoScalingConfiguration of the account and describe any stack,
+// which owns them by their stack name tags, or by their names with IncludeUntagged.
+func createAutoScalingGarbageCollection(stack awscdk.Stack, garbageCollectionProps *input.GarbageCollectionProps) {
+	allAutoScalingConfigurationArns := autoScalingConfigurationArns(stack, []string{"*"})
+
+	garbageCollectionLambda := newGoFunction(stack, "AutoScalingGarbageCollectionLambda", "./custom/autoscalinggc", &awslambda.FunctionProps{
+		Timeout: awscdk.Duration_Seconds(jsii.Number(900)),
+		InitialPolicy: &[]awsiam.PolicyStatement{
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions: &[]*string{
+					jsii.String("apprunner:DeleteAutoScalingConfiguration"),
+					jsii.String("apprunner:ListServicesForAutoScalingConfiguration"),
+					jsii.String("apprunner:ListTagsForResource"),
+				},
+				Resources: &allAutoScalingConfigurationArns,
+			}),
+			// ListAutoScalingConfigurations does not support resource-level permissions.
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions: &[]*string{
+					jsii.String("apprunner:ListAutoScalingConfigurations"),
+				},
+				Resources: &[]*string{
+					jsii.String("*"),
+				},
+			}),
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions: &[]*string{
+					jsii.String("cloudformation:DescribeStacks"),
+				},
+				Resources: &[]*string{
+					stack.FormatArn(&awscdk.ArnComponents{
+						Service:      jsii.String("cloudformation"),
+						Resource:     jsii.String("stack"),
+						ResourceName: jsii.String("*"),
+						ArnFormat:    awscdk.ArnFormat_SLASH_RESOURCE_NAME,
+					}),
+				},
+			}),
+		},
+	})
+
+	schedulerRole := awsiam.NewRole(stack, jsii.String("AutoScalingGarbageCollectionSchedulerRole"), &awsiam.RoleProps{
+		AssumedBy: awsiam.NewServicePrincipal(jsii.String("scheduler.amazonaws.com"), nil),
+	})
+	garbageCollectionLambda.GrantInvoke(schedulerRole)
+
+	allowlist := garbageCollectionProps.Allowlist
+	if allowlist == nil {
+		allowlist = []string{}
+	}
+	awsscheduler.NewCfnSchedule(stack, jsii.String("AutoScalingGarbageCollectionSchedule"), &awsscheduler.CfnScheduleProps{
+		ScheduleExpression:         jsii.String(garbageCollectionProps.Schedule),
+		ScheduleExpressionTimezone: jsii.String(garbageCollectionProps.ScheduleTimezone),
+		FlexibleTimeWindow: &awsscheduler.CfnSchedule_FlexibleTimeWindowProperty{
+			Mode: jsii.String("OFF"),
+		},
+		Target: &awsscheduler.CfnSchedule_TargetProperty{
+			Arn:     garbageCollectionLambda.FunctionArn(),
+			RoleArn: schedulerRole.RoleArn(),
+			Input: stack.ToJsonString(map[string]interface{}{
+				"DryRun":          garbageCollectionProps.DryRun,
+				"Allowlist":       allowlist,
+				"IncludeUntagged": garbageCollectionProps.IncludeUntagged,
+			}, nil),
+		},
+	})
+}
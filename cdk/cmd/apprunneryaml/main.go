@@ -0,0 +1,31 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go-cdk-go-managed-apprunner/cdk/apprunneryaml"
+	"go-cdk-go-managed-apprunner/cdk/input"
+	"os"
+)
+
+// apprunner.yaml is written to the source directory of each service.
+// -o overrides the path of the stack's own service.
+func main() {
+	appRunnerStackInputProps := input.NewAppRunnerStackInputProps()
+	sourceConfigurationProps := appRunnerStackInputProps.SourceConfigurationProps
+
+	path := flag.String("o", apprunneryaml.Path(sourceConfigurationProps.SourceDirectory), "output path of apprunner.yaml")
+	flag.Parse()
+
+	if err := apprunneryaml.Write(*path, sourceConfigurationProps, appRunnerStackInputProps.TraceEnabled()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	for _, serviceProps := range appRunnerStackInputProps.ServiceProps {
+		if err := apprunneryaml.Write(apprunneryaml.Path(serviceProps.SourceDirectory), sourceConfigurationProps.ForService(serviceProps), appRunnerStackInputProps.TraceEnabled()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+}
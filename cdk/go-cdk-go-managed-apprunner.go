@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"go-cdk-go-managed-apprunner/cdk/input"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -14,7 +15,6 @@ import (
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsec2"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
 	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
-	"github.com/aws/aws-cdk-go/awscdk/v2/awss3assets"
 	apprunner "github.com/aws/aws-cdk-go/awscdkapprunneralpha/v2"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -26,6 +26,10 @@ import (
 type AppRunnerStackProps struct {
 	awscdk.StackProps
 	AppRunnerStackInputProps *input.AppRunnerStackInputProps
+	// Offline makes the VPC from VpcConnectorProps instead of looking it up, so that the stack is synthesized without AWS access (e.g. tests).
+	Offline bool
+	// ConnectionArnProvider returns the ARN of the GitHub connection, and createConnection is used if nil.
+	ConnectionArnProvider func(connectionName string, region string) (string, error)
 }
 
 func NewAppRunnerStack(scope constructs.Construct, id string, props *AppRunnerStackProps) awscdk.Stack {
@@ -35,64 +39,109 @@ func NewAppRunnerStack(scope constructs.Construct, id string, props *AppRunnerSt
 	}
 	stack := awscdk.NewStack(scope, &id, &sprops)
 
+	/*
+		Tags of all the resources
+	*/
+	tags := stackTags(props.AppRunnerStackInputProps)
+	applyTags(stack, tags)
+
 	/*
 		Custom Resource Lambda for creation of AutoScalingConfiguration
 	*/
-	customResourceLambda := awslambda.NewFunction(stack, jsii.String("CustomResourceLambda"), &awslambda.FunctionProps{
-		Runtime: awslambda.Runtime_GO_1_X(),
-		Handler: jsii.String("main"),
-		Code: awslambda.AssetCode_FromAsset(jsii.String("../"), &awss3assets.AssetOptions{
-			Bundling: &awscdk.BundlingOptions{
-				Image:   awslambda.Runtime_GO_1_X().BundlingImage(),
-				Command: jsii.Strings("bash", "-c", "GOOS=linux GOARCH=amd64 go build -o /asset-output/main custom/custom.go"),
-				User:    jsii.String("root"),
+	// The Lambda is kept while migrating to the native resource, for the removed custom resources to delete their revisions.
+	autoScalingConfigurationResource := resolveAutoScalingConfigurationResource(props.AppRunnerStackInputProps.AutoScalingConfigurationResource)
+	stackAutoScalingConfigurationArns := autoScalingConfigurationArns(stack, autoScalingConfigurationNames(stack, props.AppRunnerStackInputProps))
+	var customResourceLambda awslambda.Function
+	var customResourceServiceToken *string
+	if autoScalingConfigurationResource != input.AutoScalingConfigurationResourceNative {
+		customResourceLambda = newGoFunction(stack, "CustomResourceLambda", "custom/custom.go", &awslambda.FunctionProps{
+			Timeout: awscdk.Duration_Seconds(jsii.Number(900)),
+			Environment: &map[string]*string{
+				"METRICS_NAMESPACE": jsii.String(customResourceMetricsNamespace),
 			},
-		}),
-		Timeout: awscdk.Duration_Seconds(jsii.Number(900)),
-		InitialPolicy: &[]awsiam.PolicyStatement{
-			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-				Actions: &[]*string{
-					jsii.String("apprunner:*AutoScalingConfiguration*"),
-					jsii.String("apprunner:UpdateService"),
-					jsii.String("apprunner:ListOperations"),
-				},
-				Resources: &[]*string{
-					jsii.String("*"),
-				},
-			}),
-			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
-				Actions: &[]*string{
-					jsii.String("cloudformation:DescribeStacks"),
-				},
-				Resources: &[]*string{
-					stack.StackId(),
-				},
-			}),
-		},
-	})
+			InitialPolicy: &[]awsiam.PolicyStatement{
+				awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+					Actions: &[]*string{
+						jsii.String("apprunner:CreateAutoScalingConfiguration"),
+						jsii.String("apprunner:DeleteAutoScalingConfiguration"),
+						jsii.String("apprunner:DescribeAutoScalingConfiguration"),
+						jsii.String("apprunner:ListTagsForResource"),
+						jsii.String("apprunner:TagResource"),
+						jsii.String("apprunner:UntagResource"),
+					},
+					Resources: &stackAutoScalingConfigurationArns,
+				}),
+				// ListAutoScalingConfigurations does not support resource-level permissions.
+				awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+					Actions: &[]*string{
+						jsii.String("apprunner:ListAutoScalingConfigurations"),
+					},
+					Resources: &[]*string{
+						jsii.String("*"),
+					},
+				}),
+				awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+					Actions: &[]*string{
+						jsii.String("cloudformation:DescribeStacks"),
+					},
+					Resources: &[]*string{
+						stack.StackId(),
+					},
+				}),
+			},
+		})
+	}
+	if autoScalingConfigurationResource == input.AutoScalingConfigurationResourceCustom {
+		customResourceServiceToken = customResourceLambda.FunctionArn()
+	}
+	// The account default is moved to the stack's AutoScalingConfiguration, and given back to DefaultConfiguration or the fallback.
+	if validateAccountDefault(props.AppRunnerStackInputProps, autoScalingConfigurationResource) {
+		accountDefaultResources := append(append([]*string{}, stackAutoScalingConfigurationArns...), autoScalingConfigurationArns(stack, []string{"DefaultConfiguration"})...)
+		accountDefaultResources = append(accountDefaultResources, fallbackAutoScalingConfigurationArns(props.AppRunnerStackInputProps)...)
+		customResourceLambda.AddToRolePolicy(awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Actions: &[]*string{
+				jsii.String("apprunner:UpdateDefaultAutoScalingConfiguration"),
+			},
+			Resources: &accountDefaultResources,
+		}))
+	}
 
 	/*
-		AutoScalingConfiguration
+		Source of AppRunner Service
 	*/
-	autoScalingConfiguration := awscdk.NewCustomResource(stack, jsii.String("AutoScalingConfiguration"), &awscdk.CustomResourceProps{
-		ResourceType: jsii.String("Custom::AutoScalingConfiguration"),
-		Properties: &map[string]interface{}{
-			"AutoScalingConfigurationName": *stack.StackName(),
-			"MaxConcurrency":               strconv.Itoa(props.AppRunnerStackInputProps.AutoScalingConfigurationArnProps.MaxConcurrency),
-			"MaxSize":                      strconv.Itoa(props.AppRunnerStackInputProps.AutoScalingConfigurationArnProps.MaxSize),
-			"MinSize":                      strconv.Itoa(props.AppRunnerStackInputProps.AutoScalingConfigurationArnProps.MinSize),
-			"StackName":                    *stack.StackName(),
-		},
-		ServiceToken: customResourceLambda.FunctionArn(),
-	})
-	autoScalingConfigurationArn := autoScalingConfiguration.GetAttString(jsii.String("AutoScalingConfigurationArn"))
+	sourceConfigurationProps := resolveSourceConfigurationProps(props.AppRunnerStackInputProps.SourceConfigurationProps, props.AppRunnerStackInputProps.TraceEnabled())
+	validateServiceProps(props.AppRunnerStackInputProps)
 
 	/*
-		ConnectionArn for GitHub Connection
+		AutoScalingConfiguration
 	*/
-	connectionArn, err := createConnection(props.AppRunnerStackInputProps.SourceConfigurationProps.ConnectionName, *props.Env.Region)
-	if err != nil {
-		panic(err)
+	sharedAutoScalingExportNames := sharedAutoScalingExportNames(stack, props.AppRunnerStackInputProps)
+	autoScalingConfigurationArn := newAutoScalingConfiguration(
+		stack,
+		"AutoScalingConfiguration",
+		*stack.StackName(),
+		props.AppRunnerStackInputProps.AutoScalingConfigurationArnProps,
+		customResourceServiceToken,
+		sharedAutoScalingExportNames,
+		tags,
+	)
+
+	// ConnectionArn for GitHub Connection
+	var connectionArn string
+	var source *imageSource
+	if sourceConfigurationProps.SourceType == input.SourceTypeCode {
+		connectionArnProvider := props.ConnectionArnProvider
+		if connectionArnProvider == nil {
+			connectionArnProvider = createConnection
+		}
+
+		var err error
+		connectionArn, err = connectionArnProvider(sourceConfigurationProps.ConnectionName, *props.Env.Region)
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		source = newImageSource(stack, sourceConfigurationProps.ImageSourceProps)
 	}
 
 	/*
@@ -101,21 +150,43 @@ func NewAppRunnerStack(scope constructs.Construct, id string, props *AppRunnerSt
 	appRunnerInstanceRole := awsiam.NewRole(stack, jsii.String("AppRunnerInstanceRole"), &awsiam.RoleProps{
 		AssumedBy: awsiam.NewServicePrincipal(jsii.String("tasks.apprunner.amazonaws.com"), nil),
 	})
+	grantInstanceRole(stack, appRunnerInstanceRole, props.AppRunnerStackInputProps.InstanceRoleGrantProps)
+
+	/*
+		ObservabilityConfiguration for AppRunner Service
+	*/
+	var serviceObservabilityConfiguration *awsapprunner.CfnService_ServiceObservabilityConfigurationProperty
+	if props.AppRunnerStackInputProps.TraceEnabled() {
+		observabilityConfiguration := awsapprunner.NewCfnObservabilityConfiguration(stack, jsii.String("ObservabilityConfiguration"), &awsapprunner.CfnObservabilityConfigurationProps{
+			TraceConfiguration: &awsapprunner.CfnObservabilityConfiguration_TraceConfigurationProperty{
+				Vendor: jsii.String("AWSXRAY"),
+			},
+		})
+
+		serviceObservabilityConfiguration = &awsapprunner.CfnService_ServiceObservabilityConfigurationProperty{
+			ObservabilityEnabled:          jsii.Bool(true),
+			ObservabilityConfigurationArn: observabilityConfiguration.AttrObservabilityConfigurationArn(),
+		}
+
+		// Traces are sent to X-Ray with the instance role
+		appRunnerInstanceRole.AddManagedPolicy(awsiam.ManagedPolicy_FromAwsManagedPolicyName(jsii.String("AWSXRayDaemonWriteAccess")))
+	}
+
+	/*
+		Preflight check of Fargate vCPU quota before creating or updating services
+	*/
+	vcpuQuotaPreflight := createVcpuQuotaPreflight(stack, props.AppRunnerStackInputProps)
 
 	/*
 		L2 Construct(alpha version) for VPC Connector
 	*/
 	securityGroupForVpcConnectorL2 := awsec2.NewSecurityGroup(stack, jsii.String("SecurityGroupForVpcConnectorL2"), &awsec2.SecurityGroupProps{
-		Vpc: awsec2.Vpc_FromLookup(stack, jsii.String("VPCForSecurityGroupForVpcConnectorL2"), &awsec2.VpcLookupOptions{
-			VpcId: jsii.String(props.AppRunnerStackInputProps.VpcConnectorProps.VpcID),
-		}),
+		Vpc:         lookupVpc(stack, "VPCForSecurityGroupForVpcConnectorL2", props),
 		Description: jsii.String("for AppRunner VPC Connector L2"),
 	})
 
 	vpcConnectorL2 := apprunner.NewVpcConnector(stack, jsii.String("VpcConnectorL2"), &apprunner.VpcConnectorProps{
-		Vpc: awsec2.Vpc_FromLookup(stack, jsii.String("VPCForVpcConnectorL2"), &awsec2.VpcLookupOptions{
-			VpcId: jsii.String(props.AppRunnerStackInputProps.VpcConnectorProps.VpcID),
-		}),
+		Vpc:            lookupVpc(stack, "VPCForVpcConnectorL2", props),
 		SecurityGroups: &[]awsec2.ISecurityGroup{securityGroupForVpcConnectorL2},
 		VpcSubnets: &awsec2.SubnetSelection{
 			Subnets: &[]awsec2.ISubnet{
@@ -129,9 +200,7 @@ func NewAppRunnerStack(scope constructs.Construct, id string, props *AppRunnerSt
 		L1 Construct for VPC Connector
 	*/
 	securityGroupForVpcConnectorL1 := awsec2.NewSecurityGroup(stack, jsii.String("SecurityGroupForVpcConnectorL1"), &awsec2.SecurityGroupProps{
-		Vpc: awsec2.Vpc_FromLookup(stack, jsii.String("VPCForVpcConnectorL1"), &awsec2.VpcLookupOptions{
-			VpcId: jsii.String(props.AppRunnerStackInputProps.VpcConnectorProps.VpcID),
-		}),
+		Vpc:         lookupVpc(stack, "VPCForVpcConnectorL1", props),
 		Description: jsii.String("for AppRunner VPC Connector L1"),
 	})
 
@@ -148,21 +217,9 @@ func NewAppRunnerStack(scope constructs.Construct, id string, props *AppRunnerSt
 	*/
 	apprunnerServiceL2 := apprunner.NewService(stack, jsii.String("AppRunnerServiceL2"), &apprunner.ServiceProps{
 		InstanceRole: appRunnerInstanceRole,
-		Source: apprunner.Source_FromGitHub(&apprunner.GithubRepositoryProps{
-			RepositoryUrl:       jsii.String(props.AppRunnerStackInputProps.SourceConfigurationProps.RepositoryUrl),
-			Branch:              jsii.String(props.AppRunnerStackInputProps.SourceConfigurationProps.BranchName),
-			ConfigurationSource: apprunner.ConfigurationSourceType_API,
-			CodeConfigurationValues: &apprunner.CodeConfigurationValues{
-				Runtime:      apprunner.Runtime_GO_1(),
-				Port:         jsii.String(strconv.Itoa(props.AppRunnerStackInputProps.SourceConfigurationProps.Port)),
-				StartCommand: jsii.String(props.AppRunnerStackInputProps.SourceConfigurationProps.StartCommand),
-				BuildCommand: jsii.String(props.AppRunnerStackInputProps.SourceConfigurationProps.BuildCommand),
-				Environment: &map[string]*string{
-					"ENV1": jsii.String("L2"),
-				},
-			},
-			Connection: apprunner.GitHubConnection_FromConnectionArn(jsii.String(connectionArn)),
-		}),
+		Source: newL2Source(sourceConfigurationProps, source, connectionArn, withTracing(map[string]string{
+			"ENV1": "L2",
+		}, props.AppRunnerStackInputProps.TraceEnabled())),
 		Cpu:                    apprunner.Cpu_Of(jsii.String(props.AppRunnerStackInputProps.InstanceConfigurationProps.Cpu)),
 		Memory:                 apprunner.Memory_Of(jsii.String(props.AppRunnerStackInputProps.InstanceConfigurationProps.Memory)),
 		VpcConnector:           vpcConnectorL2,
@@ -173,43 +230,23 @@ func NewAppRunnerStack(scope constructs.Construct, id string, props *AppRunnerSt
 	//lint:ignore SA1019 This is deprecated, but Go does not support escape hatches yet.
 	jsii.Get(apprunnerServiceL2.Node(), "defaultChild", &cfnAppRunner)
 	cfnAppRunner.SetAutoScalingConfigurationArn(autoScalingConfigurationArn)
+	setSourceDirectory(cfnAppRunner, sourceConfigurationProps)
 	cfnAppRunner.SetHealthCheckConfiguration(&awsapprunner.CfnService_HealthCheckConfigurationProperty{
 		Path:     jsii.String("/"),
 		Protocol: jsii.String("HTTP"),
 	})
+	if serviceObservabilityConfiguration != nil {
+		cfnAppRunner.SetObservabilityConfiguration(serviceObservabilityConfiguration)
+	}
+	apprunnerServiceL2.Node().AddDependency(vcpuQuotaPreflight)
 
 	/*
 		L1 Construct for AppRunner Service
 	*/
 	apprunnerServiceL1 := awsapprunner.NewCfnService(stack, jsii.String("AppRunnerServiceL1"), &awsapprunner.CfnServiceProps{
-		SourceConfiguration: &awsapprunner.CfnService_SourceConfigurationProperty{
-			AutoDeploymentsEnabled: jsii.Bool(true),
-			AuthenticationConfiguration: &awsapprunner.CfnService_AuthenticationConfigurationProperty{
-				ConnectionArn: jsii.String(connectionArn),
-			},
-			CodeRepository: &awsapprunner.CfnService_CodeRepositoryProperty{
-				RepositoryUrl: jsii.String(props.AppRunnerStackInputProps.SourceConfigurationProps.RepositoryUrl),
-				SourceCodeVersion: &awsapprunner.CfnService_SourceCodeVersionProperty{
-					Type:  jsii.String("BRANCH"),
-					Value: jsii.String(props.AppRunnerStackInputProps.SourceConfigurationProps.BranchName),
-				},
-				CodeConfiguration: &awsapprunner.CfnService_CodeConfigurationProperty{
-					ConfigurationSource: jsii.String("API"),
-					CodeConfigurationValues: &awsapprunner.CfnService_CodeConfigurationValuesProperty{
-						Runtime:      jsii.String("GO_1"),
-						Port:         jsii.String(strconv.Itoa(props.AppRunnerStackInputProps.SourceConfigurationProps.Port)),
-						StartCommand: jsii.String(props.AppRunnerStackInputProps.SourceConfigurationProps.StartCommand),
-						BuildCommand: jsii.String(props.AppRunnerStackInputProps.SourceConfigurationProps.BuildCommand),
-						RuntimeEnvironmentVariables: []interface{}{
-							&awsapprunner.CfnService_KeyValuePairProperty{
-								Name:  jsii.String("ENV1"),
-								Value: jsii.String("L1"),
-							},
-						},
-					},
-				},
-			},
-		},
+		SourceConfiguration: newL1SourceConfiguration(sourceConfigurationProps, source, connectionArn, withTracing(map[string]string{
+			"ENV1": "L1",
+		}, props.AppRunnerStackInputProps.TraceEnabled())),
 		HealthCheckConfiguration: &awsapprunner.CfnService_HealthCheckConfigurationProperty{
 			Path:     jsii.String("/"),
 			Protocol: jsii.String("HTTP"),
@@ -227,18 +264,357 @@ func NewAppRunnerStack(scope constructs.Construct, id string, props *AppRunnerSt
 		},
 		AutoScalingConfigurationArn: autoScalingConfigurationArn,
 	})
+	setSourceDirectory(apprunnerServiceL1, sourceConfigurationProps)
+	if serviceObservabilityConfiguration != nil {
+		apprunnerServiceL1.SetObservabilityConfiguration(serviceObservabilityConfiguration)
+	}
+	apprunnerServiceL1.Node().AddDependency(vcpuQuotaPreflight)
+
+	appRunnerServices := []*appRunnerService{
+		{
+			id:               "AppRunnerServiceL2",
+			serviceArn:       apprunnerServiceL2.ServiceArn(),
+			autoScalingProps: props.AppRunnerStackInputProps.AutoScalingConfigurationArnProps,
+		},
+		{
+			id:               "AppRunnerServiceL1",
+			serviceArn:       apprunnerServiceL1.AttrServiceArn(),
+			autoScalingProps: props.AppRunnerStackInputProps.AutoScalingConfigurationArnProps,
+		},
+	}
+
+	/*
+		Additional AppRunner Services from the same repository
+	*/
+	appRunnerServices = append(appRunnerServices, createAdditionalServices(stack, props.AppRunnerStackInputProps, &serviceDefaults{
+		connectionArn:               connectionArn,
+		instanceRole:                appRunnerInstanceRole,
+		vpcConnectorArn:             vpcConnectorL1.AttrVpcConnectorArn(),
+		observabilityConfiguration:  serviceObservabilityConfiguration,
+		autoScalingConfigurationArn: autoScalingConfigurationArn,
+		serviceToken:                customResourceServiceToken,
+		preflight:                   vcpuQuotaPreflight,
+		tags:                        tags,
+	})...)
+
+	/*
+		Policy of Custom Resource Lambda for the AppRunner Services
+	*/
+	// This is not in InitialPolicy because the services depend on the Lambda through the AutoScalingConfiguration,
+	// and the services are moved between the stack's AutoScalingConfigurations and DefaultConfiguration on updates.
+	// DescribeService finds the revisions still attached to the services before deleting them.
+	if customResourceLambda != nil {
+		serviceArns := []*string{}
+		for _, service := range appRunnerServices {
+			serviceArns = append(serviceArns, service.serviceArn)
+		}
+		updateServiceResources := append(append([]*string{}, serviceArns...), stackAutoScalingConfigurationArns...)
+		updateServiceResources = append(updateServiceResources, autoScalingConfigurationArns(stack, []string{"DefaultConfiguration"})...)
+		updateServiceResources = append(updateServiceResources, fallbackAutoScalingConfigurationArns(props.AppRunnerStackInputProps)...)
+		awsiam.NewPolicy(stack, jsii.String("CustomResourceLambdaServicePolicy"), &awsiam.PolicyProps{
+			Roles: &[]awsiam.IRole{customResourceLambda.Role()},
+			Statements: &[]awsiam.PolicyStatement{
+				awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+					Actions: &[]*string{
+						jsii.String("apprunner:UpdateService"),
+					},
+					Resources: &updateServiceResources,
+				}),
+				awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+					Actions: &[]*string{
+						jsii.String("apprunner:DescribeService"),
+						jsii.String("apprunner:ListOperations"),
+					},
+					Resources: &serviceArns,
+				}),
+			},
+		})
+	}
+
+	/*
+		Alarms and Dashboard for AppRunner Services
+	*/
+	if props.AppRunnerStackInputProps.MonitoringProps != nil {
+		createMonitoring(stack, appRunnerServices, props.AppRunnerStackInputProps, customResourceLambda)
+	}
+
+	/*
+		Notifications for AppRunner Service events
+	*/
+	if notificationProps := props.AppRunnerStackInputProps.NotificationProps; notificationProps != nil && notificationProps.Enabled {
+		createNotification(stack, appRunnerServices, notificationProps)
+	}
 
-	awscdk.NewCfnOutput(stack, jsii.String("AppRunnerServiceL2ServiceArn"), &awscdk.CfnOutputProps{
-		Value:      apprunnerServiceL2.ServiceArn(),
-		ExportName: jsii.String(*stack.StackName() + "AppRunnerServiceL2ServiceArn"),
+	/*
+		Scheduled Scaling by AutoScalingConfiguration profiles
+	*/
+	if len(props.AppRunnerStackInputProps.ScalingProfileProps) > 0 {
+		sharedAutoScalingServices := []*appRunnerService{}
+		for _, service := range appRunnerServices {
+			if !service.ownAutoScalingConfiguration {
+				sharedAutoScalingServices = append(sharedAutoScalingServices, service)
+			}
+		}
+		createScheduledScaling(
+			stack,
+			sharedAutoScalingServices,
+			sharedAutoScalingExportNames,
+			customResourceServiceToken,
+			props.AppRunnerStackInputProps.ScalingProfileProps,
+			tags,
+		)
+	}
+
+	/*
+		Pause and Resume schedules for non-production stages
+	*/
+	if pauseResumeScheduleProps, ok := props.AppRunnerStackInputProps.PauseResumeScheduleProps[props.AppRunnerStackInputProps.Stage]; ok {
+		createPauseResumeSchedule(stack, appRunnerServices, pauseResumeScheduleProps)
+	}
+
+	/*
+		Garbage collection of the orphaned AutoScalingConfigurations of the account
+	*/
+	if garbageCollectionProps := props.AppRunnerStackInputProps.GarbageCollectionProps; garbageCollectionProps != nil && garbageCollectionProps.Enabled {
+		createAutoScalingGarbageCollection(stack, garbageCollectionProps)
+	}
+
+	for _, service := range appRunnerServices {
+		awscdk.NewCfnOutput(stack, jsii.String(service.id+"ServiceArn"), &awscdk.CfnOutputProps{
+			Value:      service.serviceArn,
+			ExportName: jsii.String(serviceArnExportName(stack, service.id)),
+		})
+	}
+
+	return stack
+}
+
+// stackNameTagKey is the tag of the custom resources' revisions with the stack name (custom/apprunnerops),
+// by which the garbage collection finds the owner. The native revisions have aws:cloudformation:stack-name instead.
+const stackNameTagKey = "AppRunnerStackName"
+
+// nativeAutoScalingConfigurationTag marks the revisions of the native resource,
+// which the custom resources of legacy physical IDs do not delete as theirs on the migration (custom/autoscaling).
+var nativeAutoScalingConfigurationTag = map[string]string{
+	"Key":   "AutoScalingConfigurationResource",
+	"Value": input.AutoScalingConfigurationResourceNative,
+}
+
+func resolveAutoScalingConfigurationResource(autoScalingConfigurationResource string) string {
+	switch autoScalingConfigurationResource {
+	case "":
+		return input.AutoScalingConfigurationResourceCustom
+	case input.AutoScalingConfigurationResourceCustom, input.AutoScalingConfigurationResourceMigrating, input.AutoScalingConfigurationResourceNative:
+		return autoScalingConfigurationResource
+	default:
+		panic(fmt.Errorf("AutoScalingConfigurationResource must be %s, %s or %s: %s",
+			input.AutoScalingConfigurationResourceCustom,
+			input.AutoScalingConfigurationResourceMigrating,
+			input.AutoScalingConfigurationResourceNative,
+			autoScalingConfigurationResource,
+		))
+	}
+}
+
+// newAutoScalingConfiguration returns the ARN of the AutoScalingConfiguration made by the custom resource of serviceToken,
+// or by the native AWS::AppRunner::AutoScalingConfiguration if serviceToken is nil.
+func newAutoScalingConfiguration(
+	stack awscdk.Stack,
+	id string,
+	autoScalingConfigurationName string,
+	autoScalingConfigurationArnProps *input.AutoScalingConfigurationArnProps,
+	serviceToken *string,
+	serviceArnExportNames []string,
+	tags map[string]string,
+) *string {
+	// AutoScalingConfigurationName must be 4 to 32 characters.
+	if len(autoScalingConfigurationName) < 4 || len(autoScalingConfigurationName) > 32 {
+		panic(fmt.Errorf("AutoScalingConfigurationName must be 4 to 32 characters: %s", autoScalingConfigurationName))
+	}
+
+	if serviceToken == nil {
+		return newNativeAutoScalingConfiguration(stack, id, autoScalingConfigurationName, autoScalingConfigurationArnProps, tags)
+	}
+
+	properties := map[string]interface{}{
+		"AutoScalingConfigurationName": autoScalingConfigurationName,
+		"MaxConcurrency":               strconv.Itoa(autoScalingConfigurationArnProps.MaxConcurrency),
+		"MaxSize":                      strconv.Itoa(autoScalingConfigurationArnProps.MaxSize),
+		"MinSize":                      strconv.Itoa(autoScalingConfigurationArnProps.MinSize),
+		"StackName":                    *stack.StackName(),
+	}
+	// The services of the configuration, all the services of the stack if not set.
+	if serviceArnExportNames != nil {
+		properties["ServiceArnExportNames"] = serviceArnExportNames
+	}
+	if autoScalingConfigurationArnProps.RetainOnDelete {
+		properties["RetainOnDelete"] = "true"
+	}
+	if autoScalingConfigurationArnProps.FallbackAutoScalingConfigurationArn != "" {
+		if !strings.Contains(autoScalingConfigurationArnProps.FallbackAutoScalingConfigurationArn, ":autoscalingconfiguration/") {
+			panic(fmt.Errorf("FallbackAutoScalingConfigurationArn must be an AutoScalingConfiguration ARN: %s", autoScalingConfigurationArnProps.FallbackAutoScalingConfigurationArn))
+		}
+		properties["FallbackAutoScalingConfigurationArn"] = autoScalingConfigurationArnProps.FallbackAutoScalingConfigurationArn
+	}
+	if autoScalingConfigurationArnProps.AccountDefault {
+		properties["AccountDefault"] = "true"
+	}
+
+	// The tags are synced to the revision by the custom resource, not making a new revision.
+	// The stack name tag tells the garbage collection the owner of the revision.
+	resourceTags := map[string]string{}
+	for key, value := range tags {
+		resourceTags[key] = value
+	}
+	resourceTags[stackNameTagKey] = *stack.StackName()
+	properties["Tags"] = resourceTags
+
+	autoScalingConfiguration := awscdk.NewCustomResource(stack, jsii.String(id), &awscdk.CustomResourceProps{
+		ResourceType: jsii.String("Custom::AutoScalingConfiguration"),
+		Properties:   &properties,
+		ServiceToken: serviceToken,
 	})
 
-	awscdk.NewCfnOutput(stack, jsii.String("AppRunnerServiceL1ServiceArn"), &awscdk.CfnOutputProps{
-		Value:      apprunnerServiceL1.AttrServiceArn(),
-		ExportName: jsii.String(*stack.StackName() + "AppRunnerServiceL1ServiceArn"),
+	return autoScalingConfiguration.GetAttString(jsii.String("AutoScalingConfigurationArn"))
+}
+
+// newNativeAutoScalingConfiguration creates the AutoScalingConfiguration by AWS::AppRunner::AutoScalingConfiguration,
+// whose changes of the values replace it with a new revision of the name.
+// RetainOnDelete is the Retain deletion policy, and FallbackAutoScalingConfigurationArn is not used:
+// CloudFormation deletes the old revision after the services of the stack are moved to the new one.
+// Its logical ID is prefixed with Native: CloudFormation does not change the type of a resource of the same logical ID,
+// which the custom resource of MIGRATING is replaced by.
+func newNativeAutoScalingConfiguration(
+	stack awscdk.Stack,
+	id string,
+	autoScalingConfigurationName string,
+	autoScalingConfigurationArnProps *input.AutoScalingConfigurationArnProps,
+	tags map[string]string,
+) *string {
+	// The generic CfnResource is tagged through the Tags property, not by awscdk.Tags_Of.
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	resourceTags := []map[string]string{nativeAutoScalingConfigurationTag}
+	for _, key := range keys {
+		resourceTags = append(resourceTags, map[string]string{"Key": key, "Value": tags[key]})
+	}
+
+	autoScalingConfiguration := awscdk.NewCfnResource(stack, jsii.String("Native"+id), &awscdk.CfnResourceProps{
+		Type: jsii.String("AWS::AppRunner::AutoScalingConfiguration"),
+		Properties: &map[string]interface{}{
+			"AutoScalingConfigurationName": autoScalingConfigurationName,
+			"MaxConcurrency":               autoScalingConfigurationArnProps.MaxConcurrency,
+			"MaxSize":                      autoScalingConfigurationArnProps.MaxSize,
+			"MinSize":                      autoScalingConfigurationArnProps.MinSize,
+			"Tags":                         resourceTags,
+		},
 	})
+	if autoScalingConfigurationArnProps.RetainOnDelete {
+		autoScalingConfiguration.ApplyRemovalPolicy(awscdk.RemovalPolicy_RETAIN, nil)
+	}
 
-	return stack
+	return awscdk.Fn_GetAtt(autoScalingConfiguration.LogicalId(), jsii.String("AutoScalingConfigurationArn")).ToString()
+}
+
+// allAutoScalingConfigurationArnProps returns the props of the stack's AutoScalingConfigurations:
+// the stack's one, the scaling profiles and the additional services with their own AutoScalingConfigurationArnProps.
+func allAutoScalingConfigurationArnProps(inputProps *input.AppRunnerStackInputProps) []*input.AutoScalingConfigurationArnProps {
+	autoScalingConfigurationArnProps := []*input.AutoScalingConfigurationArnProps{inputProps.AutoScalingConfigurationArnProps}
+	for _, scalingProfile := range inputProps.ScalingProfileProps {
+		autoScalingConfigurationArnProps = append(autoScalingConfigurationArnProps, scalingProfile.AutoScalingConfigurationArnProps)
+	}
+	for _, serviceProps := range inputProps.ServiceProps {
+		if serviceProps.AutoScalingConfigurationArnProps != nil {
+			autoScalingConfigurationArnProps = append(autoScalingConfigurationArnProps, serviceProps.AutoScalingConfigurationArnProps)
+		}
+	}
+	return autoScalingConfigurationArnProps
+}
+
+// validateAccountDefault reports whether an AutoScalingConfiguration of the stack has AccountDefault.
+// The account has only one default, so it panics for more than one, and without the custom resource which manages it.
+func validateAccountDefault(inputProps *input.AppRunnerStackInputProps, autoScalingConfigurationResource string) bool {
+	count := 0
+	for _, props := range allAutoScalingConfigurationArnProps(inputProps) {
+		if props.AccountDefault {
+			count++
+		}
+	}
+	if count > 1 {
+		panic(fmt.Errorf("AccountDefault is set to %d AutoScalingConfigurations, but the account has only one default", count))
+	}
+	if count == 1 && autoScalingConfigurationResource != input.AutoScalingConfigurationResourceCustom {
+		panic(fmt.Errorf("AccountDefault needs AutoScalingConfigurationResource %s: %s", input.AutoScalingConfigurationResourceCustom, autoScalingConfigurationResource))
+	}
+	return count == 1
+}
+
+// fallbackAutoScalingConfigurationArns returns the distinct FallbackAutoScalingConfigurationArn of the stack's AutoScalingConfigurations.
+func fallbackAutoScalingConfigurationArns(inputProps *input.AppRunnerStackInputProps) []*string {
+	arns := []*string{}
+	seen := map[string]bool{}
+	for _, props := range allAutoScalingConfigurationArnProps(inputProps) {
+		arn := props.FallbackAutoScalingConfigurationArn
+		if arn == "" || seen[arn] {
+			continue
+		}
+		seen[arn] = true
+		arns = append(arns, jsii.String(arn))
+	}
+	return arns
+}
+
+// autoScalingConfigurationNames returns the names of all the AutoScalingConfigurations of the stack:
+// the stack's one, the scaling profiles and the additional services with their own AutoScalingConfigurationArnProps.
+func autoScalingConfigurationNames(stack awscdk.Stack, inputProps *input.AppRunnerStackInputProps) []string {
+	names := []string{*stack.StackName()}
+	for _, scalingProfile := range inputProps.ScalingProfileProps {
+		names = append(names, *stack.StackName()+"-"+scalingProfile.Name)
+	}
+	for _, serviceProps := range inputProps.ServiceProps {
+		if serviceProps.AutoScalingConfigurationArnProps != nil {
+			names = append(names, *stack.StackName()+"-"+serviceProps.Name)
+		}
+	}
+	return names
+}
+
+// autoScalingConfigurationArns returns the ARNs of all the revisions of the AutoScalingConfigurations
+// (arn:aws:apprunner:region:account:autoscalingconfiguration/name/revision/id).
+func autoScalingConfigurationArns(stack awscdk.Stack, names []string) []*string {
+	arns := []*string{}
+	for _, name := range names {
+		arns = append(arns, stack.FormatArn(&awscdk.ArnComponents{
+			Service:      jsii.String("apprunner"),
+			Resource:     jsii.String("autoscalingconfiguration"),
+			ResourceName: jsii.String(name + "/*"),
+			ArnFormat:    awscdk.ArnFormat_SLASH_RESOURCE_NAME,
+		}))
+	}
+	return arns
+}
+
+// lookupVpc looks up the VPC of VpcConnectorProps, or makes it from the VPC and subnet IDs in Offline mode.
+func lookupVpc(stack awscdk.Stack, id string, props *AppRunnerStackProps) awsec2.IVpc {
+	vpcConnectorProps := props.AppRunnerStackInputProps.VpcConnectorProps
+
+	if props.Offline {
+		return awsec2.Vpc_FromVpcAttributes(stack, jsii.String(id), &awsec2.VpcAttributes{
+			VpcId: jsii.String(vpcConnectorProps.VpcID),
+			AvailabilityZones: &[]*string{
+				awscdk.Fn_Select(jsii.Number(0), awscdk.Fn_GetAzs(nil)),
+				awscdk.Fn_Select(jsii.Number(1), awscdk.Fn_GetAzs(nil)),
+			},
+			PrivateSubnetIds: jsii.Strings(vpcConnectorProps.SubnetID1, vpcConnectorProps.SubnetID2),
+		})
+	}
+
+	return awsec2.Vpc_FromLookup(stack, jsii.String(id), &awsec2.VpcLookupOptions{
+		VpcId: jsii.String(vpcConnectorProps.VpcID),
+	})
 }
 
 func createConnection(connectionName string, region string) (string, error) {
@@ -325,13 +701,13 @@ func main() {
 	appRunnerStackInputProps := input.NewAppRunnerStackInputProps()
 
 	appRunnerStackProps := &AppRunnerStackProps{
-		awscdk.StackProps{
+		StackProps: awscdk.StackProps{
 			Env: env(
 				appRunnerStackInputProps.StackEnv.Account,
 				appRunnerStackInputProps.StackEnv.Region,
 			),
 		},
-		appRunnerStackInputProps,
+		AppRunnerStackInputProps: appRunnerStackInputProps,
 	}
 
 	NewAppRunnerStack(app, "AppRunnerGoStack", appRunnerStackProps)
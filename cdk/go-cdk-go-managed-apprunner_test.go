@@ -2,55 +2,147 @@ package main
 
 import (
 	"go-cdk-go-managed-apprunner/cdk/input"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/aws/aws-cdk-go/awscdk/v2"
 	assertions "github.com/aws/aws-cdk-go/awscdk/v2/assertions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapprunner"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	apprunner "github.com/aws/aws-cdk-go/awscdkapprunneralpha/v2"
 	"github.com/aws/jsii-runtime-go"
 	"github.com/bradleyjkemp/cupaloy/v2"
 )
 
-func TestAppRunnerStack(t *testing.T) {
-	// GIVEN
-	app := awscdk.NewApp(nil)
+const testConnectionArn = "arn:aws:apprunner:ap-northeast-1:123456789012:connection/AppRunnerConnection/xxxxxxxxxxxxxxx"
 
-	appRunnerStackInputProps := input.NewAppRunnerStackInputProps()
+// newTestApp returns an app which skips the bundling of the Lambda functions.
+func newTestApp() awscdk.App {
+	return awscdk.NewApp(&awscdk.AppProps{
+		Context: &map[string]interface{}{
+			"aws:cdk:bundling-stacks": []string{},
+		},
+	})
+}
 
-	appRunnerStackProps := &AppRunnerStackProps{
-		awscdk.StackProps{
+// newTestStackProps returns the props to synthesize the stack offline without AWS access.
+func newTestStackProps(appRunnerStackInputProps *input.AppRunnerStackInputProps) *AppRunnerStackProps {
+	return &AppRunnerStackProps{
+		StackProps: awscdk.StackProps{
 			Env: env(
 				appRunnerStackInputProps.StackEnv.Account,
 				appRunnerStackInputProps.StackEnv.Region,
 			),
 		},
-		appRunnerStackInputProps,
+		AppRunnerStackInputProps: appRunnerStackInputProps,
+		Offline:                  true,
+		ConnectionArnProvider: func(connectionName string, region string) (string, error) {
+			return testConnectionArn, nil
+		},
 	}
+}
+
+func TestAppRunnerStack(t *testing.T) {
+	// GIVEN
+	app := newTestApp()
+
+	appRunnerStackProps := newTestStackProps(input.NewAppRunnerStackInputProps())
 
 	// WHEN
 	stack := NewAppRunnerStack(app, "AppRunnerStack", appRunnerStackProps)
 
 	// THEN
 	template := assertions.Template_FromStack(stack, nil)
-	templateJson := convertSnapshot(template.ToJSON())
+	templateJson := normalizeTemplate(template.ToJSON())
 
 	t.Run("Snapshot Test", func(t *testing.T) {
 		cupaloy.SnapshotT(t, templateJson)
 	})
 
-	t.Run("CustomResourceLambda created", func(t *testing.T) {
-		template.ResourceCountIs(jsii.String("AWS::Lambda::Function"), jsii.Number(1))
+	t.Run("CustomResourceLambda and VcpuQuotaPreflightLambda created", func(t *testing.T) {
+		template.ResourceCountIs(jsii.String("AWS::Lambda::Function"), jsii.Number(2))
+	})
+
+	t.Run("Lambda functions on provided.al2023 and arm64", func(t *testing.T) {
+		functions := template.FindResources(jsii.String("AWS::Lambda::Function"), &map[string]interface{}{
+			"Properties": map[string]interface{}{
+				"Runtime":       "provided.al2023",
+				"Handler":       "bootstrap",
+				"Architectures": []interface{}{"arm64"},
+			},
+		})
+		if len(*functions) != 2 {
+			t.Errorf("%d Lambda functions on provided.al2023 and arm64, want 2", len(*functions))
+		}
 	})
 
 	t.Run("AutoScalingConfiguration created", func(t *testing.T) {
 		template.ResourceCountIs(jsii.String("Custom::AutoScalingConfiguration"), jsii.Number(1))
 	})
 
+	t.Run("VcpuQuotaPreflight created", func(t *testing.T) {
+		template.ResourceCountIs(jsii.String("Custom::VcpuQuotaPreflight"), jsii.Number(1))
+	})
+
 	t.Run("IAMRole created", func(t *testing.T) {
-		template.ResourceCountIs(jsii.String("AWS::IAM::Role"), jsii.Number(2))
+		template.ResourceCountIs(jsii.String("AWS::IAM::Role"), jsii.Number(3))
 	})
 
 	t.Run("IAMPolicy created", func(t *testing.T) {
-		template.ResourceCountIs(jsii.String("AWS::IAM::Policy"), jsii.Number(1))
+		template.ResourceCountIs(jsii.String("AWS::IAM::Policy"), jsii.Number(3))
+	})
+
+	t.Run("No wildcard App Runner permissions", func(t *testing.T) {
+		for _, statement := range policyStatements(template) {
+			actions := statement.actions()
+			if !strings.HasPrefix(actions[0], "apprunner:") {
+				continue
+			}
+
+			for _, action := range actions {
+				if strings.Contains(action, "*") {
+					t.Errorf("wildcard action %s granted in %s", action, statement.logicalId)
+				}
+			}
+
+			// ListAutoScalingConfigurations does not support resource-level permissions.
+			if len(actions) == 1 && actions[0] == "apprunner:ListAutoScalingConfigurations" {
+				continue
+			}
+			for _, resource := range statement.resources() {
+				if resource == "*" {
+					t.Errorf("%v granted on all resources in %s", actions, statement.logicalId)
+				}
+			}
+		}
+	})
+
+	t.Run("CustomResourceLambda scoped to the services and AutoScalingConfigurations", func(t *testing.T) {
+		template.HasResourceProperties(jsii.String("AWS::IAM::Policy"), &map[string]interface{}{
+			"PolicyDocument": map[string]interface{}{
+				"Statement": assertions.Match_ArrayWith(&[]interface{}{
+					map[string]interface{}{
+						"Action": "apprunner:UpdateService",
+						"Effect": "Allow",
+						"Resource": assertions.Match_ArrayWith(&[]interface{}{
+							map[string]interface{}{
+								"Fn::GetAtt": []interface{}{assertions.Match_StringLikeRegexp(jsii.String("^AppRunnerServiceL1")), "ServiceArn"},
+							},
+							map[string]interface{}{
+								"Fn::Join": []interface{}{
+									"",
+									assertions.Match_ArrayWith(&[]interface{}{
+										assertions.Match_StringLikeRegexp(jsii.String(`:autoscalingconfiguration/DefaultConfiguration/\*$`)),
+									}),
+								},
+							},
+						}),
+					},
+				}),
+			},
+		})
 	})
 
 	t.Run("SecurityGroup created", func(t *testing.T) {
@@ -65,20 +157,1366 @@ func TestAppRunnerStack(t *testing.T) {
 		template.ResourceCountIs(jsii.String("AWS::AppRunner::Service"), jsii.Number(2))
 	})
 
+	t.Run("ObservabilityConfiguration created", func(t *testing.T) {
+		template.ResourceCountIs(jsii.String("AWS::AppRunner::ObservabilityConfiguration"), jsii.Number(1))
+	})
+
+	t.Run("TRACE_ENABLED set with the ObservabilityConfiguration", func(t *testing.T) {
+		traceEnabled := map[string]interface{}{
+			"SourceConfiguration": map[string]interface{}{
+				"CodeRepository": map[string]interface{}{
+					"CodeConfiguration": map[string]interface{}{
+						"CodeConfigurationValues": map[string]interface{}{
+							"RuntimeEnvironmentVariables": assertions.Match_ArrayWith(&[]interface{}{
+								map[string]interface{}{"Name": "TRACE_ENABLED", "Value": "true"},
+							}),
+						},
+					},
+				},
+			},
+		}
+		if got := countResources(template, "AWS::AppRunner::Service", traceEnabled); got != 2 {
+			t.Errorf("%d services with TRACE_ENABLED=true, want 2", got)
+		}
+	})
+
+	t.Run("Alarms created", func(t *testing.T) {
+		template.ResourceCountIs(jsii.String("AWS::CloudWatch::Alarm"), jsii.Number(10))
+	})
+
+	t.Run("AlarmTopic created", func(t *testing.T) {
+		template.ResourceCountIs(jsii.String("AWS::SNS::Topic"), jsii.Number(1))
+	})
+
+	t.Run("Dashboard created", func(t *testing.T) {
+		template.ResourceCountIs(jsii.String("AWS::CloudWatch::Dashboard"), jsii.Number(1))
+	})
+
+	t.Run("No PauseResumeSchedule by default", func(t *testing.T) {
+		template.ResourceCountIs(jsii.String("AWS::Scheduler::Schedule"), jsii.Number(0))
+	})
+
+}
+
+func TestAppRunnerServiceProperties(t *testing.T) {
+	cases := []struct {
+		name         string
+		modify       func(inputProps *input.AppRunnerStackInputProps)
+		services     int
+		sharedConfig int               // services on the stack's AutoScalingConfiguration
+		configs      int               // AutoScalingConfigurations of the stack
+		environment  map[string]string // common environment variables, nil for the REPOSITORY configuration source
+	}{
+		{
+			name:         "Default",
+			modify:       func(inputProps *input.AppRunnerStackInputProps) {},
+			services:     2,
+			sharedConfig: 2,
+			configs:      1,
+			environment:  map[string]string{},
+		},
+		{
+			name: "Environment",
+			modify: func(inputProps *input.AppRunnerStackInputProps) {
+				inputProps.SourceConfigurationProps.Environment = map[string]string{"LOG_LEVEL": "debug", "APP_NAME": "go-cdk-go"}
+			},
+			services:     2,
+			sharedConfig: 2,
+			configs:      1,
+			environment:  map[string]string{"LOG_LEVEL": "debug", "APP_NAME": "go-cdk-go"},
+		},
+		{
+			name: "Repository configuration source",
+			modify: func(inputProps *input.AppRunnerStackInputProps) {
+				inputProps.SourceConfigurationProps.ConfigurationSource = input.ConfigurationSourceRepository
+			},
+			services:     2,
+			sharedConfig: 2,
+			configs:      1,
+		},
+		{
+			name: "Additional services and scaling profile",
+			modify: func(inputProps *input.AppRunnerStackInputProps) {
+				inputProps.ScalingProfileProps = []*input.ScalingProfileProps{
+					{
+						Name:                             "business",
+						Schedule:                         "cron(0 9 ? * MON-FRI *)",
+						ScheduleTimezone:                 "Asia/Tokyo",
+						AutoScalingConfigurationArnProps: &input.AutoScalingConfigurationArnProps{MaxConcurrency: 50, MaxSize: 3, MinSize: 2},
+					},
+				}
+				inputProps.ServiceProps = []*input.ServiceProps{
+					{
+						Name:                             "Api",
+						SourceDirectory:                  "api",
+						Port:                             8081,
+						AutoScalingConfigurationArnProps: &input.AutoScalingConfigurationArnProps{MaxConcurrency: 100, MaxSize: 2, MinSize: 1},
+					},
+					{
+						Name:            "Web",
+						SourceDirectory: "web",
+						Port:            8082,
+					},
+				}
+			},
+			services:     4,
+			sharedConfig: 3,
+			configs:      3,
+			environment:  map[string]string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// GIVEN
+			app := newTestApp()
+			appRunnerStackInputProps := input.NewAppRunnerStackInputProps()
+			tc.modify(appRunnerStackInputProps)
+
+			// WHEN
+			stack := NewAppRunnerStack(app, "AppRunnerStack", newTestStackProps(appRunnerStackInputProps))
+
+			// THEN
+			template := assertions.Template_FromStack(stack, nil)
+
+			t.Run("AutoScalingConfigurationArn wired through Fn::GetAtt", func(t *testing.T) {
+				got := countResources(template, "AWS::AppRunner::Service", map[string]interface{}{
+					"AutoScalingConfigurationArn": map[string]interface{}{
+						"Fn::GetAtt": []interface{}{"AutoScalingConfiguration", "AutoScalingConfigurationArn"},
+					},
+				})
+				if got != tc.sharedConfig {
+					t.Errorf("%d services on the stack's AutoScalingConfiguration, want %d", got, tc.sharedConfig)
+				}
+
+				got = countResources(template, "AWS::AppRunner::Service", map[string]interface{}{
+					"AutoScalingConfigurationArn": map[string]interface{}{
+						"Fn::GetAtt": []interface{}{assertions.Match_StringLikeRegexp(jsii.String("AutoScalingConfiguration")), "AutoScalingConfigurationArn"},
+					},
+				})
+				if got != tc.services {
+					t.Errorf("%d services with an AutoScalingConfiguration of the stack, want %d", got, tc.services)
+				}
+			})
+
+			t.Run("HealthCheckConfiguration", func(t *testing.T) {
+				got := countResources(template, "AWS::AppRunner::Service", map[string]interface{}{
+					"HealthCheckConfiguration": map[string]interface{}{
+						"Path":     "/",
+						"Protocol": "HTTP",
+					},
+				})
+				if got != tc.services {
+					t.Errorf("%d services with the health check, want %d", got, tc.services)
+				}
+			})
+
+			t.Run("VPC egress", func(t *testing.T) {
+				got := countResources(template, "AWS::AppRunner::Service", map[string]interface{}{
+					"NetworkConfiguration": map[string]interface{}{
+						"EgressConfiguration": map[string]interface{}{
+							"EgressType":      "VPC",
+							"VpcConnectorArn": map[string]interface{}{"Fn::GetAtt": assertions.Match_AnyValue()},
+						},
+					},
+				})
+				if got != tc.services {
+					t.Errorf("%d services with VPC egress, want %d", got, tc.services)
+				}
+			})
+
+			t.Run("Environment variables", func(t *testing.T) {
+				if tc.environment == nil {
+					got := countResources(template, "AWS::AppRunner::Service", map[string]interface{}{
+						"SourceConfiguration": map[string]interface{}{
+							"CodeRepository": map[string]interface{}{
+								"CodeConfiguration": map[string]interface{}{
+									"ConfigurationSource":     input.ConfigurationSourceRepository,
+									"CodeConfigurationValues": assertions.Match_Absent(),
+								},
+							},
+						},
+					})
+					if got != tc.services {
+						t.Errorf("%d services configured by apprunner.yaml, want %d", got, tc.services)
+					}
+					return
+				}
+
+				environmentVariable := func(name string, value string) map[string]interface{} {
+					return map[string]interface{}{
+						"SourceConfiguration": map[string]interface{}{
+							"CodeRepository": map[string]interface{}{
+								"CodeConfiguration": map[string]interface{}{
+									"CodeConfigurationValues": map[string]interface{}{
+										"RuntimeEnvironmentVariables": assertions.Match_ArrayWith(&[]interface{}{
+											map[string]interface{}{"Name": name, "Value": value},
+										}),
+									},
+								},
+							},
+						},
+					}
+				}
+
+				for _, label := range []string{"L1", "L2"} {
+					if got := countResources(template, "AWS::AppRunner::Service", environmentVariable("ENV1", label)); got != 1 {
+						t.Errorf("%d services with ENV1=%s, want 1", got, label)
+					}
+				}
+				for name, value := range tc.environment {
+					if got := countResources(template, "AWS::AppRunner::Service", environmentVariable(name, value)); got != 2 {
+						t.Errorf("%d services with %s=%s, want 2", got, name, value)
+					}
+				}
+			})
+
+			t.Run("InstanceRoleArn", func(t *testing.T) {
+				got := countResources(template, "AWS::AppRunner::Service", map[string]interface{}{
+					"InstanceConfiguration": map[string]interface{}{
+						"InstanceRoleArn": map[string]interface{}{
+							"Fn::GetAtt": []interface{}{assertions.Match_StringLikeRegexp(jsii.String("^AppRunnerInstanceRole")), "Arn"},
+						},
+					},
+				})
+				if got != tc.services {
+					t.Errorf("%d services with the instance role, want %d", got, tc.services)
+				}
+			})
+
+			t.Run("CustomResourceLambda policy statements", func(t *testing.T) {
+				customResourceLambdaRole := []interface{}{
+					map[string]interface{}{"Ref": assertions.Match_StringLikeRegexp(jsii.String("^CustomResourceLambdaServiceRole"))},
+				}
+
+				template.HasResourceProperties(jsii.String("AWS::IAM::Policy"), &map[string]interface{}{
+					"Roles": customResourceLambdaRole,
+					"PolicyDocument": map[string]interface{}{
+						"Statement": assertions.Match_ArrayWith(&[]interface{}{
+							map[string]interface{}{
+								"Action": []interface{}{
+									"apprunner:CreateAutoScalingConfiguration",
+									"apprunner:DeleteAutoScalingConfiguration",
+									"apprunner:DescribeAutoScalingConfiguration",
+									"apprunner:ListTagsForResource",
+									"apprunner:TagResource",
+									"apprunner:UntagResource",
+								},
+								"Effect":   "Allow",
+								"Resource": assertions.Match_AnyValue(),
+							},
+							map[string]interface{}{
+								"Action":   "apprunner:ListAutoScalingConfigurations",
+								"Effect":   "Allow",
+								"Resource": "*",
+							},
+							map[string]interface{}{
+								"Action":   "cloudformation:DescribeStacks",
+								"Effect":   "Allow",
+								"Resource": map[string]interface{}{"Ref": "AWS::StackId"},
+							},
+						}),
+					},
+				})
+
+				template.HasResourceProperties(jsii.String("AWS::IAM::Policy"), &map[string]interface{}{
+					"Roles": customResourceLambdaRole,
+					"PolicyDocument": map[string]interface{}{
+						"Statement": []interface{}{
+							map[string]interface{}{
+								"Action":   "apprunner:UpdateService",
+								"Effect":   "Allow",
+								"Resource": assertions.Match_AnyValue(),
+							},
+							map[string]interface{}{
+								"Action": []interface{}{
+									"apprunner:DescribeService",
+									"apprunner:ListOperations",
+								},
+								"Effect":   "Allow",
+								"Resource": assertions.Match_AnyValue(),
+							},
+						},
+					},
+				})
+
+				// AutoScalingConfigurations of the stack, the services (and DefaultConfiguration)
+				wantResources := map[string]int{
+					"apprunner:CreateAutoScalingConfiguration": tc.configs,
+					"apprunner:UpdateService":                  tc.services + tc.configs + 1,
+					"apprunner:DescribeService":                tc.services,
+				}
+				for _, statement := range policyStatements(template) {
+					if !statement.attachedTo("CustomResourceLambdaServiceRole") {
+						continue
+					}
+					want, ok := wantResources[statement.actions()[0]]
+					if !ok {
+						continue
+					}
+					if got := statement.resourceCount(); got != want {
+						t.Errorf("%s granted on %d resources, want %d", statement.actions()[0], got, want)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestObservabilityConfiguration(t *testing.T) {
+	traceEnabled := map[string]interface{}{
+		"SourceConfiguration": map[string]interface{}{
+			"CodeRepository": map[string]interface{}{
+				"CodeConfiguration": map[string]interface{}{
+					"CodeConfigurationValues": map[string]interface{}{
+						"RuntimeEnvironmentVariables": assertions.Match_ArrayWith(&[]interface{}{
+							map[string]interface{}{"Name": "TRACE_ENABLED", "Value": "true"},
+						}),
+					},
+				},
+			},
+		},
+	}
+
+	t.Run("no tracing without ObservabilityConfigurationProps", func(t *testing.T) {
+		inputProps := input.NewAppRunnerStackInputProps()
+		inputProps.ObservabilityConfigurationProps = nil
+
+		stack := NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+		template := assertions.Template_FromStack(stack, nil)
+
+		template.ResourceCountIs(jsii.String("AWS::AppRunner::ObservabilityConfiguration"), jsii.Number(0))
+		if got := countResources(template, "AWS::AppRunner::Service", traceEnabled); got != 0 {
+			t.Errorf("%d services with TRACE_ENABLED=true, want 0", got)
+		}
+	})
+
+	// The REPOSITORY configuration source takes TRACE_ENABLED from apprunner.yaml, which is generated with it by default.
+	t.Run("apprunner.yaml checked for TRACE_ENABLED", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("no panic for apprunner.yaml with TRACE_ENABLED while tracing is disabled")
+			}
+		}()
+		inputProps := input.NewAppRunnerStackInputProps()
+		inputProps.SourceConfigurationProps.ConfigurationSource = input.ConfigurationSourceRepository
+		inputProps.ObservabilityConfigurationProps.TraceEnabled = false
+		NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+	})
+}
+
+func TestServicePortRejected(t *testing.T) {
+	for _, port := range []int{0, 65536} {
+		t.Run(strconv.Itoa(port), func(t *testing.T) {
+			inputProps := input.NewAppRunnerStackInputProps()
+			inputProps.ServiceProps = []*input.ServiceProps{
+				{Name: "Api", SourceDirectory: "api", Port: port},
+			}
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("no panic for Port %d", port)
+				}
+			}()
+			NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+		})
+	}
+}
+
+func TestImageSource(t *testing.T) {
+	cases := []struct {
+		name             string
+		imageSourceProps *input.ImageSourceProps
+		imageIdentifier  map[string]interface{}
+	}{
+		{
+			name: "ECR repository and tag",
+			imageSourceProps: &input.ImageSourceProps{
+				EcrRepositoryName: "my-repository",
+				ImageTag:          "v1",
+			},
+			imageIdentifier: map[string]interface{}{
+				"Fn::Join": []interface{}{
+					"",
+					assertions.Match_ArrayWith(&[]interface{}{
+						assertions.Match_StringLikeRegexp(jsii.String(`/my-repository:v1$`)),
+					}),
+				},
+			},
+		},
+		{
+			name: "DockerImageAsset",
+			imageSourceProps: &input.ImageSourceProps{
+				Directory:  "app",
+				Dockerfile: "Dockerfile",
+			},
+			imageIdentifier: map[string]interface{}{
+				"Fn::Sub": assertions.Match_StringLikeRegexp(jsii.String(`container-assets`)),
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// GIVEN
+			inputProps := input.NewAppRunnerStackInputProps()
+			inputProps.SourceConfigurationProps.SourceType = input.SourceTypeImage
+			inputProps.SourceConfigurationProps.ImageSourceProps = tc.imageSourceProps
+
+			// WHEN
+			stack := NewAppRunnerStack(newTestApp(), "AppRunnerStack", newTestStackProps(inputProps))
+
+			// THEN
+			template := assertions.Template_FromStack(stack, nil)
+
+			t.Run("ImageRepository on both services", func(t *testing.T) {
+				got := countResources(template, "AWS::AppRunner::Service", map[string]interface{}{
+					"SourceConfiguration": map[string]interface{}{
+						"AutoDeploymentsEnabled": true,
+						"AuthenticationConfiguration": map[string]interface{}{
+							"AccessRoleArn": map[string]interface{}{
+								"Fn::GetAtt": []interface{}{assertions.Match_AnyValue(), "Arn"},
+							},
+						},
+						"ImageRepository": map[string]interface{}{
+							"ImageIdentifier":     tc.imageIdentifier,
+							"ImageRepositoryType": "ECR",
+							"ImageConfiguration": map[string]interface{}{
+								"Port": "8080",
+							},
+						},
+					},
+				})
+				if got != 2 {
+					t.Errorf("%d services with the image, want 2", got)
+				}
+
+				got = countResources(template, "AWS::AppRunner::Service", map[string]interface{}{
+					"SourceConfiguration": map[string]interface{}{
+						"CodeRepository": assertions.Match_AnyValue(),
+					},
+				})
+				if got != 0 {
+					t.Errorf("%d services with the code repository, want none", got)
+				}
+			})
+
+			t.Run("Access role of the L1 service", func(t *testing.T) {
+				got := countResources(template, "AWS::AppRunner::Service", map[string]interface{}{
+					"SourceConfiguration": map[string]interface{}{
+						"AuthenticationConfiguration": map[string]interface{}{
+							"AccessRoleArn": map[string]interface{}{
+								"Fn::GetAtt": []interface{}{assertions.Match_StringLikeRegexp(jsii.String("^AppRunnerEcrAccessRole")), "Arn"},
+							},
+						},
+					},
+				})
+				if got != 1 {
+					t.Errorf("%d services with AppRunnerEcrAccessRole, want 1", got)
+				}
+
+				template.HasResourceProperties(jsii.String("AWS::IAM::Role"), &map[string]interface{}{
+					"AssumeRolePolicyDocument": map[string]interface{}{
+						"Statement": []interface{}{
+							map[string]interface{}{
+								"Action":    "sts:AssumeRole",
+								"Effect":    "Allow",
+								"Principal": map[string]interface{}{"Service": "build.apprunner.amazonaws.com"},
+							},
+						},
+					},
+				})
+				template.HasResourceProperties(jsii.String("AWS::IAM::Policy"), &map[string]interface{}{
+					"PolicyDocument": map[string]interface{}{
+						"Statement": assertions.Match_ArrayWith(&[]interface{}{
+							assertions.Match_ObjectLike(&map[string]interface{}{
+								"Action": assertions.Match_ArrayWith(&[]interface{}{"ecr:BatchGetImage"}),
+								"Effect": "Allow",
+							}),
+						}),
+					},
+					"Roles": []interface{}{
+						map[string]interface{}{
+							"Ref": assertions.Match_StringLikeRegexp(jsii.String("^AppRunnerEcrAccessRole")),
+						},
+					},
+				})
+			})
+		})
+	}
+}
+
+func TestAutoScalingConfigurationDeletion(t *testing.T) {
+	fallbackArn := "arn:aws:apprunner:ap-northeast-1:123456789012:autoscalingconfiguration/Shared/1/0123456789abcdef0123456789abcdef"
+
+	inputProps := input.NewAppRunnerStackInputProps()
+	inputProps.AutoScalingConfigurationArnProps.FallbackAutoScalingConfigurationArn = fallbackArn
+	inputProps.ScalingProfileProps = []*input.ScalingProfileProps{
+		{
+			Name:                             "business",
+			Schedule:                         "cron(0 9 ? * MON-FRI *)",
+			ScheduleTimezone:                 "Asia/Tokyo",
+			AutoScalingConfigurationArnProps: &input.AutoScalingConfigurationArnProps{MaxConcurrency: 50, MaxSize: 3, MinSize: 2, RetainOnDelete: true},
+		},
+	}
+
+	app := newTestApp()
+	stack := NewAppRunnerStack(app, "TestAppRunnerStack", newTestStackProps(inputProps))
+	template := assertions.Template_FromStack(stack, nil)
+
+	template.HasResourceProperties(jsii.String("Custom::AutoScalingConfiguration"), &map[string]interface{}{
+		"AutoScalingConfigurationName":        "TestAppRunnerStack",
+		"FallbackAutoScalingConfigurationArn": fallbackArn,
+		"RetainOnDelete":                      assertions.Match_Absent(),
+	})
+	template.HasResourceProperties(jsii.String("Custom::AutoScalingConfiguration"), &map[string]interface{}{
+		"AutoScalingConfigurationName":        "TestAppRunnerStack-business",
+		"FallbackAutoScalingConfigurationArn": assertions.Match_Absent(),
+		"RetainOnDelete":                      "true",
+	})
+
+	granted := false
+	for _, statement := range policyStatements(template) {
+		if !statement.attachedTo("CustomResourceLambdaServiceRole") || statement.actions()[0] != "apprunner:UpdateService" {
+			continue
+		}
+		for _, resource := range statement.resources() {
+			if resource == fallbackArn {
+				granted = true
+			}
+		}
+	}
+	if !granted {
+		t.Errorf("UpdateService is not granted on %s", fallbackArn)
+	}
+}
+
+func TestCustomResourceMonitoring(t *testing.T) {
+	app := newTestApp()
+	stack := NewAppRunnerStack(app, "TestAppRunnerStack", newTestStackProps(input.NewAppRunnerStackInputProps()))
+	template := assertions.Template_FromStack(stack, nil)
+
+	template.HasResourceProperties(jsii.String("AWS::Lambda::Function"), &map[string]interface{}{
+		"Environment": map[string]interface{}{
+			"Variables": map[string]interface{}{
+				"METRICS_NAMESPACE": customResourceMetricsNamespace,
+			},
+		},
+	})
+
+	template.HasResourceProperties(jsii.String("AWS::CloudWatch::Alarm"), &map[string]interface{}{
+		"Namespace":  customResourceMetricsNamespace,
+		"MetricName": "Failures",
+		"Dimensions": []interface{}{
+			map[string]interface{}{"Name": "StackName", "Value": "TestAppRunnerStack"},
+		},
+		"Statistic":          "Sum",
+		"Threshold":          1,
+		"ComparisonOperator": "GreaterThanOrEqualToThreshold",
+		"AlarmActions": []interface{}{
+			map[string]interface{}{"Ref": assertions.Match_StringLikeRegexp(jsii.String("^AlarmTopic"))},
+		},
+	})
+
+	// The timeouts and the panics of the Lambda, which write no Failures.
+	template.HasResourceProperties(jsii.String("AWS::CloudWatch::Alarm"), &map[string]interface{}{
+		"Namespace":  "AWS/Lambda",
+		"MetricName": "Errors",
+		"Dimensions": []interface{}{
+			map[string]interface{}{
+				"Name":  "FunctionName",
+				"Value": map[string]interface{}{"Ref": assertions.Match_StringLikeRegexp(jsii.String("^CustomResourceLambda"))},
+			},
+		},
+		"Statistic":          "Sum",
+		"Threshold":          1,
+		"ComparisonOperator": "GreaterThanOrEqualToThreshold",
+		"AlarmActions": []interface{}{
+			map[string]interface{}{"Ref": assertions.Match_StringLikeRegexp(jsii.String("^AlarmTopic"))},
+		},
+	})
+}
+
+func TestNoMonitoring(t *testing.T) {
+	inputProps := input.NewAppRunnerStackInputProps()
+	inputProps.MonitoringProps = nil
+
+	stack := NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+	template := assertions.Template_FromStack(stack, nil)
+
+	template.ResourceCountIs(jsii.String("AWS::CloudWatch::Alarm"), jsii.Number(0))
+	template.ResourceCountIs(jsii.String("AWS::CloudWatch::Dashboard"), jsii.Number(0))
+	template.ResourceCountIs(jsii.String("AWS::SNS::Topic"), jsii.Number(0))
+}
+
+func TestNotification(t *testing.T) {
+	inputProps := input.NewAppRunnerStackInputProps()
+	inputProps.NotificationProps = &input.NotificationProps{
+		Enabled:              true,
+		WebhookUrlSecretName: "apprunner/slack-webhook-url",
+		NotificationEmails:   []string{"ops@example.com"},
+	}
+
+	stack := NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+	template := assertions.Template_FromStack(stack, nil)
+
+	template.HasResourceProperties(jsii.String("AWS::SNS::Subscription"), &map[string]interface{}{
+		"Protocol": "email",
+		"Endpoint": "ops@example.com",
+		"TopicArn": map[string]interface{}{"Ref": assertions.Match_StringLikeRegexp(jsii.String("^NotificationTopic"))},
+	})
+
+	// Only the secret name is in the environment, and the URL is read at runtime.
+	template.HasResourceProperties(jsii.String("AWS::Lambda::Function"), &map[string]interface{}{
+		"Environment": map[string]interface{}{
+			"Variables": map[string]interface{}{
+				"TOPIC_ARN":             map[string]interface{}{"Ref": assertions.Match_StringLikeRegexp(jsii.String("^NotificationTopic"))},
+				"WEBHOOK_URL_SECRET_ID": "apprunner/slack-webhook-url",
+				"WEBHOOK_URL":           assertions.Match_Absent(),
+			},
+		},
+	})
+
+	granted := false
+	for _, statement := range policyStatements(template) {
+		if !statement.attachedTo("NotificationLambda") {
+			continue
+		}
+		for _, action := range statement.actions() {
+			if action == "secretsmanager:GetSecretValue" {
+				granted = true
+			}
+		}
+	}
+	if !granted {
+		t.Error("NotificationLambda is not granted secretsmanager:GetSecretValue")
+	}
+
+	rules := *template.FindResources(jsii.String("AWS::Events::Rule"), nil)
+	if len(rules) != 1 {
+		t.Fatalf("%d Events::Rule, want 1", len(rules))
+	}
+	for _, rule := range rules {
+		properties := rule["Properties"].(map[string]interface{})
+		eventPattern := properties["EventPattern"].(map[string]interface{})
+		if got := toStrings(eventPattern["source"]); !reflect.DeepEqual(got, []string{"aws.apprunner"}) {
+			t.Errorf("source = %v", got)
+		}
+		if got := toStrings(eventPattern["detail-type"]); !reflect.DeepEqual(got, []string{"AppRunner Service Status Change", "AppRunner Service Operation Status Change"}) {
+			t.Errorf("detail-type = %v", got)
+		}
+
+		// The rule matches only the events of the stack's services, by their ARNs.
+		resources := eventPattern["resources"].([]interface{})
+		if len(resources) != 2 {
+			t.Fatalf("resources = %v, want the ARNs of the 2 services", resources)
+		}
+		for _, resource := range resources {
+			getAtt, _ := resource.(map[string]interface{})["Fn::GetAtt"].([]interface{})
+			if len(getAtt) != 2 || !strings.HasPrefix(getAtt[0].(string), "AppRunnerService") || getAtt[1] != "ServiceArn" {
+				t.Errorf("resource = %v, want a service ARN", resource)
+			}
+		}
+
+		targets := properties["Targets"].([]interface{})
+		if len(targets) != 1 {
+			t.Fatalf("%d targets, want 1", len(targets))
+		}
+		arn := targets[0].(map[string]interface{})["Arn"].(map[string]interface{})["Fn::GetAtt"].([]interface{})
+		if !strings.HasPrefix(arn[0].(string), "NotificationLambda") {
+			t.Errorf("target = %v, want NotificationLambda", arn)
+		}
+	}
+}
+
+func TestNoNotification(t *testing.T) {
+	inputProps := input.NewAppRunnerStackInputProps()
+	inputProps.NotificationProps = nil
+
+	stack := NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+	template := assertions.Template_FromStack(stack, nil)
+
+	template.ResourceCountIs(jsii.String("AWS::Events::Rule"), jsii.Number(0))
+}
+
+func TestTags(t *testing.T) {
+	inputProps := input.NewAppRunnerStackInputProps()
+	inputProps.ScalingProfileProps = []*input.ScalingProfileProps{
+		{
+			Name:                             "business",
+			Schedule:                         "cron(0 9 ? * MON-FRI *)",
+			ScheduleTimezone:                 "Asia/Tokyo",
+			AutoScalingConfigurationArnProps: &input.AutoScalingConfigurationArnProps{MaxConcurrency: 50, MaxSize: 3, MinSize: 2},
+		},
+	}
+	inputProps.Tags = map[string]string{"CostCenter": "1234", "Team": "platform"}
+
+	app := newTestApp()
+	stack := NewAppRunnerStack(app, "TestAppRunnerStack", newTestStackProps(inputProps))
+	template := assertions.Template_FromStack(stack, nil)
+
+	wantTags := []interface{}{
+		map[string]interface{}{"Key": "CostCenter", "Value": "1234"},
+		map[string]interface{}{"Key": "Stage", "Value": "dev"},
+		map[string]interface{}{"Key": "Team", "Value": "platform"},
+	}
+	for _, resourceType := range []string{"AWS::Lambda::Function", "AWS::SNS::Topic", "AWS::IAM::Role"} {
+		if got, all := countResources(template, resourceType, map[string]interface{}{
+			"Tags": assertions.Match_ArrayWith(&wantTags),
+		}), len(*template.FindResources(jsii.String(resourceType), nil)); got != all {
+			t.Errorf("%d of %d %s tagged", got, all, resourceType)
+		}
+	}
+
+	// A tag change would replace them.
+	for _, resourceType := range untaggedResourceTypes {
+		if got := countResources(template, resourceType, map[string]interface{}{
+			"Tags": assertions.Match_Absent(),
+		}); got != len(*template.FindResources(jsii.String(resourceType), nil)) {
+			t.Errorf("%s tagged", resourceType)
+		}
+	}
+
+	// Both the stack's AutoScalingConfiguration and the profile's.
+	if got := countResources(template, "Custom::AutoScalingConfiguration", map[string]interface{}{
+		"Tags": map[string]interface{}{"AppRunnerStackName": "TestAppRunnerStack", "CostCenter": "1234", "Stage": "dev", "Team": "platform"},
+	}); got != 2 {
+		t.Errorf("%d AutoScalingConfigurations with the tags, want 2", got)
+	}
+
+	t.Run("services unchanged by adding the tags", func(t *testing.T) {
+		untaggedProps := input.NewAppRunnerStackInputProps()
+		untaggedProps.Stage = ""
+		untaggedProps.Tags = nil
+		untagged := assertions.Template_FromStack(NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(untaggedProps)), nil)
+
+		for _, resourceType := range untaggedResourceTypes {
+			before := *untagged.FindResources(jsii.String(resourceType), nil)
+			after := *template.FindResources(jsii.String(resourceType), nil)
+			if len(after) != len(before) {
+				t.Errorf("%d %s, want %d", len(after), resourceType, len(before))
+			}
+			for logicalId, resource := range before {
+				if !reflect.DeepEqual(after[logicalId], resource) {
+					t.Errorf("%s %s changed by the tags:\n%v\nwant\n%v", resourceType, logicalId, after[logicalId], resource)
+				}
+			}
+		}
+	})
+
+	t.Run("aws: prefix rejected", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("no panic for an aws: tag key")
+			}
+		}()
+		inputProps := input.NewAppRunnerStackInputProps()
+		inputProps.Tags = map[string]string{"aws:cloudformation:stack-name": "x"}
+		NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+	})
 }
 
-func convertSnapshot(templateJson *map[string]interface{}) map[string]interface{} {
-	resources := (*templateJson)["Resources"].(map[string]interface{})
-	for key := range resources {
-		if valProperties, ok := resources[key].(map[string]interface{})["Properties"]; ok {
-			properties := valProperties.(map[string]interface{})
-			if valCode, ok := properties["Code"]; ok {
-				code := valCode.(map[string]interface{})
-				if _, ok := code["S3Key"]; ok {
-					(*templateJson)["Resources"].(map[string]interface{})[key].(map[string]interface{})["Properties"].(map[string]interface{})["Code"].(map[string]interface{})["S3Key"] = ""
+func TestAutoScalingConfigurationResource(t *testing.T) {
+	cases := []struct {
+		name                 string
+		resource             string
+		customResources      int
+		nativeResources      int
+		customResourceLambda bool
+		customResourceAlarm  bool
+	}{
+		{name: "custom", resource: input.AutoScalingConfigurationResourceCustom, customResources: 2, customResourceLambda: true, customResourceAlarm: true},
+		{name: "empty is custom", resource: "", customResources: 2, customResourceLambda: true, customResourceAlarm: true},
+		{name: "migrating keeps the Lambda for the cleanup", resource: input.AutoScalingConfigurationResourceMigrating, nativeResources: 2, customResourceLambda: true, customResourceAlarm: true},
+		{name: "native", resource: input.AutoScalingConfigurationResourceNative, nativeResources: 2},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			inputProps := input.NewAppRunnerStackInputProps()
+			inputProps.AutoScalingConfigurationResource = tc.resource
+			inputProps.AutoScalingConfigurationArnProps.RetainOnDelete = true
+			inputProps.ScalingProfileProps = []*input.ScalingProfileProps{
+				{
+					Name:                             "business",
+					Schedule:                         "cron(0 9 ? * MON-FRI *)",
+					ScheduleTimezone:                 "Asia/Tokyo",
+					AutoScalingConfigurationArnProps: &input.AutoScalingConfigurationArnProps{MaxConcurrency: 50, MaxSize: 3, MinSize: 2},
+				},
+			}
+
+			app := newTestApp()
+			stack := NewAppRunnerStack(app, "TestAppRunnerStack", newTestStackProps(inputProps))
+			template := assertions.Template_FromStack(stack, nil)
+
+			template.ResourceCountIs(jsii.String("Custom::AutoScalingConfiguration"), jsii.Number(float64(tc.customResources)))
+			template.ResourceCountIs(jsii.String("AWS::AppRunner::AutoScalingConfiguration"), jsii.Number(float64(tc.nativeResources)))
+
+			lambdas := countResources(template, "AWS::Lambda::Function", map[string]interface{}{
+				"Environment": map[string]interface{}{
+					"Variables": map[string]interface{}{"METRICS_NAMESPACE": customResourceMetricsNamespace},
+				},
+			})
+			if got := lambdas == 1; got != tc.customResourceLambda {
+				t.Errorf("CustomResourceLambda created = %v, want %v", got, tc.customResourceLambda)
+			}
+			alarms := countResources(template, "AWS::CloudWatch::Alarm", map[string]interface{}{
+				"Namespace": customResourceMetricsNamespace,
+			})
+			if got := alarms == 1; got != tc.customResourceAlarm {
+				t.Errorf("CustomResourceFailuresAlarm created = %v, want %v", got, tc.customResourceAlarm)
+			}
+			errorsAlarms := countResources(template, "AWS::CloudWatch::Alarm", map[string]interface{}{
+				"Namespace":  "AWS/Lambda",
+				"MetricName": "Errors",
+			})
+			if got := errorsAlarms == 1; got != tc.customResourceAlarm {
+				t.Errorf("CustomResourceLambdaErrorsAlarm created = %v, want %v", got, tc.customResourceAlarm)
+			}
+
+			if tc.nativeResources == 0 {
+				return
+			}
+
+			template.HasResource(jsii.String("AWS::AppRunner::AutoScalingConfiguration"), &map[string]interface{}{
+				"Properties": map[string]interface{}{
+					"AutoScalingConfigurationName": "TestAppRunnerStack",
+					"MaxConcurrency":               50,
+					"MaxSize":                      3,
+					"MinSize":                      1,
+					"Tags": assertions.Match_ArrayWith(&[]interface{}{
+						map[string]interface{}{"Key": "AutoScalingConfigurationResource", "Value": "NATIVE"},
+						map[string]interface{}{"Key": "Stage", "Value": "dev"},
+					}),
+				},
+				"DeletionPolicy": "Retain",
+			})
+			template.HasResource(jsii.String("AWS::AppRunner::AutoScalingConfiguration"), &map[string]interface{}{
+				"Properties": map[string]interface{}{
+					"AutoScalingConfigurationName": "TestAppRunnerStack-business",
+				},
+				"DeletionPolicy": assertions.Match_Absent(),
+			})
+
+			// The services refer to the native resource.
+			if got := countResources(template, "AWS::AppRunner::Service", map[string]interface{}{
+				"AutoScalingConfigurationArn": map[string]interface{}{
+					"Fn::GetAtt": []interface{}{assertions.Match_StringLikeRegexp(jsii.String("^NativeAutoScalingConfiguration")), "AutoScalingConfigurationArn"},
+				},
+			}); got != 2 {
+				t.Errorf("%d services on the native AutoScalingConfiguration, want 2", got)
+			}
+		})
+	}
+
+	// MIGRATING replaces the custom resources by the native ones, and CloudFormation rejects a logical ID changing its type.
+	t.Run("logical IDs not reused by migrating", func(t *testing.T) {
+		templates := map[string]assertions.Template{}
+		for _, resource := range []string{input.AutoScalingConfigurationResourceCustom, input.AutoScalingConfigurationResourceMigrating} {
+			inputProps := input.NewAppRunnerStackInputProps()
+			inputProps.AutoScalingConfigurationResource = resource
+			inputProps.ScalingProfileProps = []*input.ScalingProfileProps{
+				{
+					Name:                             "business",
+					Schedule:                         "cron(0 9 ? * MON-FRI *)",
+					ScheduleTimezone:                 "Asia/Tokyo",
+					AutoScalingConfigurationArnProps: &input.AutoScalingConfigurationArnProps{MaxConcurrency: 50, MaxSize: 3, MinSize: 2},
+				},
+			}
+			stack := NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+			templates[resource] = assertions.Template_FromStack(stack, nil)
+		}
+
+		customResources := *templates[input.AutoScalingConfigurationResourceCustom].FindResources(jsii.String("Custom::AutoScalingConfiguration"), nil)
+		nativeResources := *templates[input.AutoScalingConfigurationResourceMigrating].FindResources(jsii.String("AWS::AppRunner::AutoScalingConfiguration"), nil)
+		if len(customResources) != 2 || len(nativeResources) != 2 {
+			t.Fatalf("%d custom and %d native resources, want 2 each", len(customResources), len(nativeResources))
+		}
+		for logicalID := range nativeResources {
+			if _, ok := customResources[logicalID]; ok {
+				t.Errorf("logical ID %s of the custom resource reused by the native resource", logicalID)
+			}
+		}
+	})
+
+	t.Run("unknown resource rejected", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("no panic for an unknown AutoScalingConfigurationResource")
+			}
+		}()
+		inputProps := input.NewAppRunnerStackInputProps()
+		inputProps.AutoScalingConfigurationResource = "native"
+		NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+	})
+}
+
+func TestAccountDefault(t *testing.T) {
+	t.Run("custom resource", func(t *testing.T) {
+		inputProps := input.NewAppRunnerStackInputProps()
+		inputProps.AutoScalingConfigurationArnProps.AccountDefault = true
+
+		app := newTestApp()
+		stack := NewAppRunnerStack(app, "TestAppRunnerStack", newTestStackProps(inputProps))
+		template := assertions.Template_FromStack(stack, nil)
+
+		template.HasResourceProperties(jsii.String("Custom::AutoScalingConfiguration"), &map[string]interface{}{
+			"AutoScalingConfigurationName": "TestAppRunnerStack",
+			"AccountDefault":               "true",
+		})
+
+		// The stack's AutoScalingConfiguration and DefaultConfiguration, which the default is given back to.
+		granted := false
+		for _, statement := range policyStatements(template) {
+			if !statement.attachedTo("CustomResourceLambdaServiceRole") || statement.actions()[0] != "apprunner:UpdateDefaultAutoScalingConfiguration" {
+				continue
+			}
+			granted = true
+			if got := statement.resourceCount(); got != 2 {
+				t.Errorf("UpdateDefaultAutoScalingConfiguration granted on %d resources, want 2", got)
+			}
+		}
+		if !granted {
+			t.Error("UpdateDefaultAutoScalingConfiguration not granted to the custom resource Lambda")
+		}
+	})
+
+	t.Run("not granted without AccountDefault", func(t *testing.T) {
+		stack := NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(input.NewAppRunnerStackInputProps()))
+		for _, statement := range policyStatements(assertions.Template_FromStack(stack, nil)) {
+			if statement.actions()[0] == "apprunner:UpdateDefaultAutoScalingConfiguration" {
+				t.Error("UpdateDefaultAutoScalingConfiguration granted without AccountDefault")
+			}
+		}
+	})
+
+	rejected := []struct {
+		name      string
+		configure func(inputProps *input.AppRunnerStackInputProps)
+	}{
+		{
+			name: "native resource",
+			configure: func(inputProps *input.AppRunnerStackInputProps) {
+				inputProps.AutoScalingConfigurationResource = input.AutoScalingConfigurationResourceNative
+			},
+		},
+		{
+			name: "migrating",
+			configure: func(inputProps *input.AppRunnerStackInputProps) {
+				inputProps.AutoScalingConfigurationResource = input.AutoScalingConfigurationResourceMigrating
+			},
+		},
+		{
+			name: "more than one",
+			configure: func(inputProps *input.AppRunnerStackInputProps) {
+				inputProps.ScalingProfileProps = []*input.ScalingProfileProps{
+					{
+						Name:                             "business",
+						Schedule:                         "cron(0 9 ? * MON-FRI *)",
+						ScheduleTimezone:                 "Asia/Tokyo",
+						AutoScalingConfigurationArnProps: &input.AutoScalingConfigurationArnProps{MaxConcurrency: 50, MaxSize: 3, MinSize: 2, AccountDefault: true},
+					},
 				}
+			},
+		},
+	}
+	for _, tc := range rejected {
+		t.Run(tc.name+" rejected", func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("no panic")
+				}
+			}()
+			inputProps := input.NewAppRunnerStackInputProps()
+			inputProps.AutoScalingConfigurationArnProps.AccountDefault = true
+			tc.configure(inputProps)
+			NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+		})
+	}
+}
+
+func TestPauseResumeSchedule(t *testing.T) {
+	newInputProps := func() *input.AppRunnerStackInputProps {
+		inputProps := input.NewAppRunnerStackInputProps()
+		inputProps.PauseResumeScheduleProps["dev"] = &input.PauseResumeScheduleProps{
+			PauseSchedule:    "cron(0 22 ? * MON-FRI *)",
+			ResumeSchedule:   "cron(0 8 ? * MON-FRI *)",
+			ScheduleTimezone: "Asia/Tokyo",
+		}
+		return inputProps
+	}
+
+	t.Run("created on the stage", func(t *testing.T) {
+		inputProps := newInputProps()
+		stack := NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+		template := assertions.Template_FromStack(stack, nil)
+
+		template.ResourceCountIs(jsii.String("AWS::Lambda::Function"), jsii.Number(3))
+		template.ResourceCountIs(jsii.String("AWS::Scheduler::Schedule"), jsii.Number(2))
+		for expression, action := range map[string]string{
+			"cron(0 22 ? * MON-FRI *)": "pause",
+			"cron(0 8 ? * MON-FRI *)":  "resume",
+		} {
+			template.HasResourceProperties(jsii.String("AWS::Scheduler::Schedule"), &map[string]interface{}{
+				"ScheduleExpression":         expression,
+				"ScheduleExpressionTimezone": "Asia/Tokyo",
+				"Target": map[string]interface{}{
+					"Input": assertions.Match_StringLikeRegexp(jsii.String(`"Action":"` + action + `"`)),
+				},
+			})
+		}
+	})
+
+	t.Run("not created on the other stages", func(t *testing.T) {
+		inputProps := newInputProps()
+		inputProps.Stage = "prod"
+		stack := NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(inputProps))
+		template := assertions.Template_FromStack(stack, nil)
+
+		template.ResourceCountIs(jsii.String("AWS::Scheduler::Schedule"), jsii.Number(0))
+	})
+}
+
+func TestAutoScalingGarbageCollection(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		stack := NewAppRunnerStack(newTestApp(), "TestAppRunnerStack", newTestStackProps(input.NewAppRunnerStackInputProps()))
+		template := assertions.Template_FromStack(stack, nil)
+		if got := countResources(template, "AWS::Scheduler::Schedule", map[string]interface{}{
+			"Target": map[string]interface{}{
+				"Input": assertions.Match_StringLikeRegexp(jsii.String(`"Allowlist"`)),
+			},
+		}); got != 0 {
+			t.Errorf("%d garbage collection schedules, want none", got)
+		}
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		inputProps := input.NewAppRunnerStackInputProps()
+		inputProps.GarbageCollectionProps.Enabled = true
+		inputProps.GarbageCollectionProps.Allowlist = []string{"shared-*"}
+
+		app := newTestApp()
+		stack := NewAppRunnerStack(app, "TestAppRunnerStack", newTestStackProps(inputProps))
+		template := assertions.Template_FromStack(stack, nil)
+
+		template.HasResourceProperties(jsii.String("AWS::Scheduler::Schedule"), &map[string]interface{}{
+			"ScheduleExpression":         "cron(0 3 ? * SUN *)",
+			"ScheduleExpressionTimezone": "Asia/Tokyo",
+			"Target": map[string]interface{}{
+				"Input": assertions.Match_StringLikeRegexp(jsii.String(`"DryRun":true`)),
+			},
+		})
+		if got := countResources(template, "AWS::Scheduler::Schedule", map[string]interface{}{
+			"Target": map[string]interface{}{
+				"Input": assertions.Match_StringLikeRegexp(jsii.String(`"Allowlist":\["shared-\*"\]`)),
+			},
+		}); got != 1 {
+			t.Errorf("%d schedules with the allowlist, want 1", got)
+		}
+
+		// Every AutoScalingConfiguration of the account, not only the stack's.
+		template.HasResourceProperties(jsii.String("AWS::IAM::Policy"), &map[string]interface{}{
+			"PolicyDocument": map[string]interface{}{
+				"Statement": assertions.Match_ArrayWith(&[]interface{}{
+					map[string]interface{}{
+						"Action": []interface{}{
+							"apprunner:DeleteAutoScalingConfiguration",
+							"apprunner:ListServicesForAutoScalingConfiguration",
+							"apprunner:ListTagsForResource",
+						},
+						"Effect": "Allow",
+						"Resource": map[string]interface{}{
+							"Fn::Join": []interface{}{
+								"",
+								assertions.Match_ArrayWith(&[]interface{}{
+									assertions.Match_StringLikeRegexp(jsii.String(`:autoscalingconfiguration/\*/\*$`)),
+								}),
+							},
+						},
+					},
+				}),
+			},
+			"Roles": []interface{}{
+				map[string]interface{}{
+					"Ref": assertions.Match_StringLikeRegexp(jsii.String("^AutoScalingGarbageCollectionLambdaServiceRole")),
+				},
+			},
+		})
+	})
+}
+
+// countResources returns the number of the resources of the type which match the properties.
+func countResources(template assertions.Template, resourceType string, properties map[string]interface{}) int {
+	return len(*template.FindResources(jsii.String(resourceType), &map[string]interface{}{
+		"Properties": properties,
+	}))
+}
+
+func TestRuntimes(t *testing.T) {
+	for _, runtime := range input.RuntimeNames() {
+		t.Run(runtime, func(t *testing.T) {
+			// GIVEN
+			app := awscdk.NewApp(nil)
+			stack := awscdk.NewStack(app, jsii.String("RuntimeStack"), nil)
+
+			sourceProps, err := (&input.SourceConfigurationProps{
+				SourceType:          input.SourceTypeCode,
+				ConfigurationSource: input.ConfigurationSourceApi,
+				RepositoryUrl:       "https://github.com/go-to-k/go-cdk-go-managed-apprunner",
+				BranchName:          "master",
+				Runtime:             runtime,
+				Port:                8080,
+			}).Resolve()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// WHEN
+			apprunner.NewService(stack, jsii.String("AppRunnerServiceL2"), &apprunner.ServiceProps{
+				Source: newL2Source(sourceProps, nil, testConnectionArn, nil),
+			})
+			awsapprunner.NewCfnService(stack, jsii.String("AppRunnerServiceL1"), &awsapprunner.CfnServiceProps{
+				SourceConfiguration: newL1SourceConfiguration(sourceProps, nil, testConnectionArn, nil),
+			})
+
+			// THEN
+			template := assertions.Template_FromStack(stack, nil)
+			services := template.FindResources(jsii.String("AWS::AppRunner::Service"), &map[string]interface{}{
+				"Properties": map[string]interface{}{
+					"SourceConfiguration": map[string]interface{}{
+						"CodeRepository": map[string]interface{}{
+							"CodeConfiguration": map[string]interface{}{
+								"ConfigurationSource": input.ConfigurationSourceApi,
+								"CodeConfigurationValues": map[string]interface{}{
+									"Runtime":      runtime,
+									"BuildCommand": sourceProps.BuildCommand,
+									"StartCommand": sourceProps.StartCommand,
+									"Port":         "8080",
+								},
+							},
+						},
+					},
+				},
+			})
+			if len(*services) != 2 {
+				t.Errorf("L1 and L2 services of %s are not rendered with the runtime and the default commands: %v", runtime, template.ToJSON())
+			}
+		})
+	}
+
+	t.Run("Unsupported runtime", func(t *testing.T) {
+		if _, err := (&input.SourceConfigurationProps{Runtime: "GO_2"}).Resolve(); err == nil {
+			t.Error("expected an error for an unsupported runtime")
+		}
+	})
+}
+
+func TestGrantInstanceRole(t *testing.T) {
+	// GIVEN
+	app := awscdk.NewApp(nil)
+	stack := awscdk.NewStack(app, jsii.String("GrantStack"), &awscdk.StackProps{
+		Env: env("123456789012", "ap-northeast-1"),
+	})
+	instanceRole := awsiam.NewRole(stack, jsii.String("AppRunnerInstanceRole"), &awsiam.RoleProps{
+		AssumedBy: awsiam.NewServicePrincipal(jsii.String("tasks.apprunner.amazonaws.com"), nil),
+	})
+
+	// WHEN
+	grantInstanceRole(stack, instanceRole, &input.InstanceRoleGrantProps{
+		S3Buckets:         []*input.ResourceGrantProps{{Name: "my-bucket", Access: input.AccessReadWrite}},
+		DynamoDBTables:    []*input.ResourceGrantProps{{Name: "my-table", Access: input.AccessReadWrite}},
+		SqsQueues:         []*input.ResourceGrantProps{{Name: "my-queue", Access: input.AccessReadWrite}},
+		SsmParameterPaths: []*input.ResourceGrantProps{{Name: "/myapp/dev", Access: input.AccessRead}},
+	})
+
+	// THEN
+	template := assertions.Template_FromStack(stack, nil)
+
+	t.Run("No wildcard actions granted", func(t *testing.T) {
+		statements := policyStatements(template)
+		if len(statements) == 0 {
+			t.Fatal("no policy granted to the instance role")
+		}
+
+		for _, statement := range statements {
+			for _, action := range statement.actions() {
+				if strings.Contains(action, "*") {
+					t.Errorf("wildcard action %s granted in %s", action, statement.logicalId)
+				}
+			}
+		}
+	})
+
+	t.Run("Scoped to the resources", func(t *testing.T) {
+		template.HasResourceProperties(jsii.String("AWS::IAM::Policy"), &map[string]interface{}{
+			"PolicyDocument": map[string]interface{}{
+				"Statement": assertions.Match_ArrayWith(&[]interface{}{
+					map[string]interface{}{
+						"Action":   "s3:GetObject",
+						"Effect":   "Allow",
+						"Resource": assertions.Match_AnyValue(),
+					},
+					map[string]interface{}{
+						"Action":   []interface{}{"ssm:GetParameter", "ssm:GetParameters", "ssm:GetParametersByPath"},
+						"Effect":   "Allow",
+						"Resource": assertions.Match_AnyValue(),
+					},
+				}),
+			},
+		})
+	})
+
+	t.Run("Invalid access", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected a panic for an invalid access")
+			}
+		}()
+		grantInstanceRole(stack, instanceRole, &input.InstanceRoleGrantProps{
+			S3Buckets: []*input.ResourceGrantProps{{Name: "my-bucket", Access: "FULL"}},
+		})
+	})
+}
+
+func TestParseVcpu(t *testing.T) {
+	cases := []struct {
+		cpu     string
+		want    float64
+		wantErr bool
+	}{
+		{cpu: "1 vCPU", want: 1},
+		{cpu: "0.25 vCPU", want: 0.25},
+		{cpu: "2vCPU", want: 2},
+		{cpu: "1024", want: 1},
+		{cpu: "4096", want: 4},
+		{cpu: "one vCPU", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.cpu, func(t *testing.T) {
+			got, err := parseVcpu(tc.cpu)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, but got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Errorf("parseVcpu(%q) = %v, want %v", tc.cpu, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRequiredVcpu(t *testing.T) {
+	inputProps := input.NewAppRunnerStackInputProps()
+	inputProps.InstanceConfigurationProps.Cpu = "1 vCPU"
+	inputProps.AutoScalingConfigurationArnProps.MaxSize = 3
+	inputProps.ScalingProfileProps = []*input.ScalingProfileProps{
+		{
+			Name:                             "business-hours",
+			AutoScalingConfigurationArnProps: &input.AutoScalingConfigurationArnProps{MaxSize: 5},
+		},
+	}
+	inputProps.ServiceProps = []*input.ServiceProps{
+		// On the stack's AutoScalingConfiguration, so switched by the scaling profiles
+		{
+			Name:                       "Web",
+			InstanceConfigurationProps: &input.InstanceConfigurationProps{Cpu: "2 vCPU"},
+		},
+		// With its own AutoScalingConfiguration
+		{
+			Name:                             "Api",
+			InstanceConfigurationProps:       &input.InstanceConfigurationProps{Cpu: "0.25 vCPU"},
+			AutoScalingConfigurationArnProps: &input.AutoScalingConfigurationArnProps{MaxSize: 2},
+		},
+	}
+
+	got, breakdown, err := requiredVcpu(inputProps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// L2 and L1: 1 x 5, Web: 2 x 5, Api: 0.25 x 2
+	if want := 20.5; got != want {
+		t.Errorf("requiredVcpu() = %v, want %v", got, want)
+	}
+	if len(breakdown) != 4 {
+		t.Errorf("breakdown has %d services, want 4: %v", len(breakdown), breakdown)
+	}
+}
+
+type policyStatement struct {
+	logicalId string
+	roles     []interface{}
+	statement map[string]interface{}
+}
+
+// policyStatements returns the statements of all the AWS::IAM::Policy resources in the template.
+func policyStatements(template assertions.Template) []*policyStatement {
+	statements := []*policyStatement{}
+	for logicalId, policy := range *template.FindResources(jsii.String("AWS::IAM::Policy"), nil) {
+		properties := policy["Properties"].(map[string]interface{})
+		roles, _ := properties["Roles"].([]interface{})
+		document := properties["PolicyDocument"].(map[string]interface{})
+		for _, statement := range document["Statement"].([]interface{}) {
+			statements = append(statements, &policyStatement{
+				logicalId: logicalId,
+				roles:     roles,
+				statement: statement.(map[string]interface{}),
+			})
+		}
+	}
+	return statements
+}
+
+// attachedTo returns whether the policy is attached to a role of the stack whose logical ID starts with rolePrefix.
+func (s *policyStatement) attachedTo(rolePrefix string) bool {
+	for _, role := range s.roles {
+		ref, ok := role.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if logicalId, ok := ref["Ref"].(string); ok && strings.HasPrefix(logicalId, rolePrefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resourceCount returns the number of the resources including the intrinsic functions.
+// A single resource is rendered without an array.
+func (s *policyStatement) resourceCount() int {
+	if resources, ok := s.statement["Resource"].([]interface{}); ok {
+		return len(resources)
+	}
+	return 1
+}
+
+func (s *policyStatement) actions() []string {
+	return toStrings(s.statement["Action"])
+}
+
+// resources returns the literal resources, and the intrinsic functions such as Fn::GetAtt are skipped.
+func (s *policyStatement) resources() []string {
+	return toStrings(s.statement["Resource"])
+}
+
+func toStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := []string{}
+		for _, element := range v {
+			if str, ok := element.(string); ok {
+				values = append(values, str)
 			}
 		}
+		return values
 	}
-	return resources
+	return []string{}
 }
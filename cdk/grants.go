@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"go-cdk-go-managed-apprunner/cdk/input"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// grantStatement is a set of actions on the resources of a name.
+// The actions are listed one by one without wildcards to keep the instance role least-privilege.
+type grantStatement struct {
+	actions   []string
+	resources func(stack awscdk.Stack, name string) []*string
+}
+
+// resourceGrant is the statements of the read and write access to a resource type.
+type resourceGrant struct {
+	read  []*grantStatement
+	write []*grantStatement
+}
+
+func s3BucketArn(stack awscdk.Stack, name string) []*string {
+	return []*string{
+		stack.FormatArn(&awscdk.ArnComponents{
+			Service:  jsii.String("s3"),
+			Region:   jsii.String(""),
+			Account:  jsii.String(""),
+			Resource: jsii.String(name),
+		}),
+	}
+}
+
+func s3ObjectArn(stack awscdk.Stack, name string) []*string {
+	return []*string{
+		stack.FormatArn(&awscdk.ArnComponents{
+			Service:      jsii.String("s3"),
+			Region:       jsii.String(""),
+			Account:      jsii.String(""),
+			Resource:     jsii.String(name),
+			ResourceName: jsii.String("*"),
+			ArnFormat:    awscdk.ArnFormat_SLASH_RESOURCE_NAME,
+		}),
+	}
+}
+
+func dynamoDBTableArn(stack awscdk.Stack, name string) []*string {
+	return []*string{
+		stack.FormatArn(&awscdk.ArnComponents{
+			Service:      jsii.String("dynamodb"),
+			Resource:     jsii.String("table"),
+			ResourceName: jsii.String(name),
+			ArnFormat:    awscdk.ArnFormat_SLASH_RESOURCE_NAME,
+		}),
+		stack.FormatArn(&awscdk.ArnComponents{
+			Service:      jsii.String("dynamodb"),
+			Resource:     jsii.String("table"),
+			ResourceName: jsii.String(name + "/index/*"),
+			ArnFormat:    awscdk.ArnFormat_SLASH_RESOURCE_NAME,
+		}),
+	}
+}
+
+func sqsQueueArn(stack awscdk.Stack, name string) []*string {
+	return []*string{
+		stack.FormatArn(&awscdk.ArnComponents{
+			Service:   jsii.String("sqs"),
+			Resource:  jsii.String(name),
+			ArnFormat: awscdk.ArnFormat_NO_RESOURCE_NAME,
+		}),
+	}
+}
+
+func ssmParameterPathArn(stack awscdk.Stack, name string) []*string {
+	path := strings.Trim(name, "/")
+	return []*string{
+		stack.FormatArn(&awscdk.ArnComponents{
+			Service:      jsii.String("ssm"),
+			Resource:     jsii.String("parameter"),
+			ResourceName: jsii.String(path),
+			ArnFormat:    awscdk.ArnFormat_SLASH_RESOURCE_NAME,
+		}),
+		stack.FormatArn(&awscdk.ArnComponents{
+			Service:      jsii.String("ssm"),
+			Resource:     jsii.String("parameter"),
+			ResourceName: jsii.String(path + "/*"),
+			ArnFormat:    awscdk.ArnFormat_SLASH_RESOURCE_NAME,
+		}),
+	}
+}
+
+var (
+	s3BucketGrant = &resourceGrant{
+		read: []*grantStatement{
+			{actions: []string{"s3:ListBucket"}, resources: s3BucketArn},
+			{actions: []string{"s3:GetObject"}, resources: s3ObjectArn},
+		},
+		write: []*grantStatement{
+			{actions: []string{"s3:PutObject", "s3:DeleteObject"}, resources: s3ObjectArn},
+		},
+	}
+	dynamoDBTableGrant = &resourceGrant{
+		read: []*grantStatement{
+			{
+				actions: []string{
+					"dynamodb:GetItem",
+					"dynamodb:BatchGetItem",
+					"dynamodb:Query",
+					"dynamodb:Scan",
+					"dynamodb:ConditionCheckItem",
+					"dynamodb:DescribeTable",
+				},
+				resources: dynamoDBTableArn,
+			},
+		},
+		write: []*grantStatement{
+			{
+				actions: []string{
+					"dynamodb:PutItem",
+					"dynamodb:UpdateItem",
+					"dynamodb:DeleteItem",
+					"dynamodb:BatchWriteItem",
+				},
+				resources: dynamoDBTableArn,
+			},
+		},
+	}
+	sqsQueueGrant = &resourceGrant{
+		read: []*grantStatement{
+			{
+				actions: []string{
+					"sqs:ReceiveMessage",
+					"sqs:DeleteMessage",
+					"sqs:ChangeMessageVisibility",
+					"sqs:GetQueueAttributes",
+					"sqs:GetQueueUrl",
+				},
+				resources: sqsQueueArn,
+			},
+		},
+		write: []*grantStatement{
+			{
+				actions: []string{
+					"sqs:SendMessage",
+					"sqs:GetQueueAttributes",
+					"sqs:GetQueueUrl",
+				},
+				resources: sqsQueueArn,
+			},
+		},
+	}
+	ssmParameterPathGrant = &resourceGrant{
+		read: []*grantStatement{
+			{
+				actions: []string{
+					"ssm:GetParameter",
+					"ssm:GetParameters",
+					"ssm:GetParametersByPath",
+				},
+				resources: ssmParameterPathArn,
+			},
+		},
+		write: []*grantStatement{
+			{
+				actions: []string{
+					"ssm:PutParameter",
+					"ssm:DeleteParameter",
+				},
+				resources: ssmParameterPathArn,
+			},
+		},
+	}
+)
+
+// statements returns the statements of the access to the resource.
+func (g *resourceGrant) statements(stack awscdk.Stack, grantProps *input.ResourceGrantProps) []awsiam.PolicyStatement {
+	var grantStatements []*grantStatement
+	switch grantProps.Access {
+	case input.AccessRead:
+		grantStatements = g.read
+	case input.AccessWrite:
+		grantStatements = g.write
+	case input.AccessReadWrite:
+		grantStatements = append(append(grantStatements, g.read...), g.write...)
+	default:
+		panic(fmt.Errorf("Access must be %s, %s or %s: %s (%s)", input.AccessRead, input.AccessWrite, input.AccessReadWrite, grantProps.Access, grantProps.Name))
+	}
+
+	if grantProps.Name == "" {
+		panic(fmt.Errorf("Name of the resource to grant access to is empty"))
+	}
+
+	statements := []awsiam.PolicyStatement{}
+	for _, grantStatement := range grantStatements {
+		resources := grantStatement.resources(stack, grantProps.Name)
+		statements = append(statements, awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+			Actions:   jsii.Strings(grantStatement.actions...),
+			Resources: &resources,
+		}))
+	}
+	return statements
+}
+
+// grantInstanceRole adds the policies of InstanceRoleGrantProps to the instance role of the services.
+func grantInstanceRole(stack awscdk.Stack, instanceRole awsiam.IRole, grantProps *input.InstanceRoleGrantProps) {
+	if grantProps == nil {
+		return
+	}
+
+	grants := []struct {
+		resourceGrant *resourceGrant
+		resources     []*input.ResourceGrantProps
+	}{
+		{resourceGrant: s3BucketGrant, resources: grantProps.S3Buckets},
+		{resourceGrant: dynamoDBTableGrant, resources: grantProps.DynamoDBTables},
+		{resourceGrant: sqsQueueGrant, resources: grantProps.SqsQueues},
+		{resourceGrant: ssmParameterPathGrant, resources: grantProps.SsmParameterPaths},
+	}
+
+	for _, grant := range grants {
+		for _, resource := range grant.resources {
+			for _, statement := range grant.resourceGrant.statements(stack, resource) {
+				instanceRole.AddToPrincipalPolicy(statement)
+			}
+		}
+	}
+}
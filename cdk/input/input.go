@@ -1,11 +1,26 @@
 package input
 
 type AppRunnerStackInputProps struct {
+	Stage                            string
 	StackEnv                         *StackEnv
 	VpcConnectorProps                *VpcConnectorProps
 	SourceConfigurationProps         *SourceConfigurationProps
 	InstanceConfigurationProps       *InstanceConfigurationProps
+	InstanceRoleGrantProps           *InstanceRoleGrantProps
 	AutoScalingConfigurationArnProps *AutoScalingConfigurationArnProps
+	AutoScalingConfigurationResource string // AutoScalingConfigurationResourceCustom if empty
+	ObservabilityConfigurationProps  *ObservabilityConfigurationProps
+	MonitoringProps                  *MonitoringProps
+	NotificationProps                *NotificationProps
+	ServiceProps                     []*ServiceProps
+	ScalingProfileProps              []*ScalingProfileProps
+	PauseResumeScheduleProps         map[string]*PauseResumeScheduleProps
+	GarbageCollectionProps           *GarbageCollectionProps
+	// Tags are added to every taggable resource of the stack and the AutoScalingConfigurations,
+	// with a Stage tag of Stage unless Tags has it.
+	// The App Runner services, the VPC connector and the ObservabilityConfiguration are not tagged,
+	// because CloudFormation replaces them on a tag change.
+	Tags map[string]string
 }
 
 type StackEnv struct {
@@ -19,13 +34,94 @@ type VpcConnectorProps struct {
 	SubnetID2 string
 }
 
+const (
+	SourceTypeCode  = "CODE"
+	SourceTypeImage = "IMAGE"
+)
+
+const (
+	ConfigurationSourceApi        = "API"
+	ConfigurationSourceRepository = "REPOSITORY"
+)
+
+// The AutoScalingConfigurations are created by the custom resource (Custom::AutoScalingConfiguration) and its Lambda,
+// or by the native AWS::AppRunner::AutoScalingConfiguration.
+// A stack of the custom resource is migrated to the native resource by a deployment with Migrating and then one with Native:
+// Migrating creates the native resources, which the services move to once, and keeps the Lambda for the custom resources
+// to delete their revisions in the cleanup, and Native then removes the Lambda without touching the services.
+const (
+	AutoScalingConfigurationResourceCustom    = "CUSTOM"
+	AutoScalingConfigurationResourceMigrating = "MIGRATING"
+	AutoScalingConfigurationResourceNative    = "NATIVE"
+)
+
+// Runtime is one of the managed runtimes in runtime.go (GO_1 if empty),
+// and BuildCommand and StartCommand are the defaults of the runtime if empty.
+// BuildCommand and StartCommand run in SourceDirectory (relative to the repository root, the root if empty).
+// With ConfigurationSourceRepository, the build and run settings are read from apprunner.yaml in SourceDirectory,
+// which is generated from these props by `make apprunner-yaml` in cdk.
 type SourceConfigurationProps struct {
-	RepositoryUrl  string
-	BranchName     string
-	BuildCommand   string
-	StartCommand   string
-	Port           int
-	ConnectionName string
+	SourceType          string // SourceTypeCode or SourceTypeImage
+	ConfigurationSource string // ConfigurationSourceApi or ConfigurationSourceRepository
+	RepositoryUrl       string
+	BranchName          string
+	SourceDirectory     string
+	Runtime             string
+	BuildCommand        string
+	StartCommand        string
+	Port                int
+	Environment         map[string]string
+	ConnectionName      string
+	ImageSourceProps    *ImageSourceProps
+}
+
+// An existing ECR repository is used if EcrRepositoryName is set,
+// otherwise the Dockerfile in Directory (relative to the repository root) is built as an asset.
+type ImageSourceProps struct {
+	EcrRepositoryName string
+	ImageTag          string
+	Directory         string
+	Dockerfile        string
+}
+
+// ServiceProps is an additional service "AppRunnerService<Name>" built from SourceDirectory
+// of the same repository and connection as SourceConfigurationProps (SourceTypeCode only).
+// Runtime is that of SourceConfigurationProps if empty,
+// and InstanceConfigurationProps and AutoScalingConfigurationArnProps are those of the stack if nil.
+// A service with its own AutoScalingConfigurationArnProps gets its own AutoScalingConfiguration "<StackName>-<Name>"
+// and is not switched by the scaling profiles.
+type ServiceProps struct {
+	Name                             string
+	SourceDirectory                  string
+	Runtime                          string
+	BuildCommand                     string
+	StartCommand                     string
+	Port                             int
+	Environment                      map[string]string
+	InstanceConfigurationProps       *InstanceConfigurationProps
+	AutoScalingConfigurationArnProps *AutoScalingConfigurationArnProps
+}
+
+// ForService returns the source settings of the additional service, which share the repository, branch and connection.
+func (p *SourceConfigurationProps) ForService(serviceProps *ServiceProps) *SourceConfigurationProps {
+	runtime := serviceProps.Runtime
+	if runtime == "" {
+		runtime = p.Runtime
+	}
+
+	return &SourceConfigurationProps{
+		SourceType:          p.SourceType,
+		ConfigurationSource: p.ConfigurationSource,
+		RepositoryUrl:       p.RepositoryUrl,
+		BranchName:          p.BranchName,
+		SourceDirectory:     serviceProps.SourceDirectory,
+		Runtime:             runtime,
+		BuildCommand:        serviceProps.BuildCommand,
+		StartCommand:        serviceProps.StartCommand,
+		Port:                serviceProps.Port,
+		Environment:         serviceProps.Environment,
+		ConnectionName:      p.ConnectionName,
+	}
 }
 
 type InstanceConfigurationProps struct {
@@ -33,14 +129,100 @@ type InstanceConfigurationProps struct {
 	Memory string
 }
 
+const (
+	AccessRead      = "READ"
+	AccessWrite     = "WRITE"
+	AccessReadWrite = "READ_WRITE"
+)
+
+// InstanceRoleGrantProps lists the AWS resources of the stack's account and region that the application accesses
+// with the instance role, which is granted only the actions of Access (see cdk/grants.go) on them.
+type InstanceRoleGrantProps struct {
+	S3Buckets         []*ResourceGrantProps // Name is the bucket name
+	DynamoDBTables    []*ResourceGrantProps // Name is the table name, and the indexes are also granted
+	SqsQueues         []*ResourceGrantProps // Name is the queue name
+	SsmParameterPaths []*ResourceGrantProps // Name is the parameter path such as "/myapp/dev", and the parameters under it are also granted
+}
+
+type ResourceGrantProps struct {
+	Name   string
+	Access string // AccessRead, AccessWrite or AccessReadWrite
+}
+
+// On the deletion of the AutoScalingConfiguration, the services of the stack still using it (e.g. retained services)
+// are moved to FallbackAutoScalingConfigurationArn, or the account default if empty.
+// RetainOnDelete keeps the AutoScalingConfiguration for the services outside the stack sharing it.
+// AccountDefault makes it the default AutoScalingConfiguration of the account, for the services created without one.
+// It is given back to FallbackAutoScalingConfigurationArn, or DefaultConfiguration, when turned off or deleted.
+// Only one AutoScalingConfiguration of the stack can have it, with AutoScalingConfigurationResourceCustom.
 type AutoScalingConfigurationArnProps struct {
-	MaxConcurrency int
-	MaxSize        int
-	MinSize        int
+	MaxConcurrency                      int
+	MaxSize                             int
+	MinSize                             int
+	RetainOnDelete                      bool
+	FallbackAutoScalingConfigurationArn string
+	AccountDefault                      bool
+}
+
+type ObservabilityConfigurationProps struct {
+	TraceEnabled bool
+}
+
+// TraceEnabled reports whether the services send traces to X-Ray, false without ObservabilityConfigurationProps.
+func (p *AppRunnerStackInputProps) TraceEnabled() bool {
+	return p.ObservabilityConfigurationProps != nil && p.ObservabilityConfigurationProps.TraceEnabled
+}
+
+// Saturation alarms use MaxSize and MaxConcurrency of AutoScalingConfigurationArnProps as their thresholds.
+// Without MonitoringProps, neither the alarms nor the dashboard are created.
+type MonitoringProps struct {
+	Http5xxThreshold      int
+	LatencyP99ThresholdMs int
+	NotificationEmails    []string
+}
+
+// Service status and operation events are sent to an SNS topic and, if WebhookUrlSecretName is set, to a Slack compatible webhook.
+// The secret holds the webhook URL as its plain text SecretString.
+type NotificationProps struct {
+	Enabled              bool
+	WebhookUrlSecretName string
+	NotificationEmails   []string
+}
+
+// Each profile is created as its own AutoScalingConfiguration named "<StackName>-<Name>",
+// and the services are moved onto it by EventBridge Scheduler at the Schedule.
+type ScalingProfileProps struct {
+	Name                             string
+	Schedule                         string // e.g. "cron(0 9 ? * MON-FRI *)"
+	ScheduleTimezone                 string // e.g. "Asia/Tokyo"
+	AutoScalingConfigurationArnProps *AutoScalingConfigurationArnProps
+}
+
+// PauseResumeScheduleProps is keyed by Stage, and the services are paused and resumed only on the stages in the map.
+type PauseResumeScheduleProps struct {
+	PauseSchedule    string // e.g. "cron(0 22 ? * MON-FRI *)"
+	ResumeSchedule   string // e.g. "cron(0 8 ? * MON-FRI *)"
+	ScheduleTimezone string // e.g. "Asia/Tokyo"
+}
+
+// GarbageCollectionProps schedules the deletion of the orphaned AutoScalingConfigurations of the account (custom/autoscalinggc):
+// the revisions without services whose stacks are gone, except DefaultConfiguration, the account default and Allowlist.
+// The stack of a revision is its AppRunnerStackName or aws:cloudformation:stack-name tag.
+// The revisions without them are kept, unless IncludeUntagged, which infers the stacks from the names.
+// DryRun only reports them in the logs and the result of the Lambda.
+// Enable it on one stack of the account, since the garbage collection covers all the AutoScalingConfigurations of the account.
+type GarbageCollectionProps struct {
+	Enabled          bool
+	Schedule         string   // e.g. "cron(0 3 ? * SUN *)"
+	ScheduleTimezone string   // e.g. "Asia/Tokyo"
+	DryRun           bool     // report the orphans without deleting them
+	Allowlist        []string // path.Match patterns of the names or the ARNs, e.g. "shared-*"
+	IncludeUntagged  bool     // also the revisions without the stack name tags, by "<StackName>" or "<StackName>-<Name>"
 }
 
 func NewAppRunnerStackInputProps() *AppRunnerStackInputProps {
 	return &AppRunnerStackInputProps{
+		Stage: "dev",
 		StackEnv: &StackEnv{
 			Account: "123456789012", // Your Account ID
 			Region:  "ap-northeast-1",
@@ -51,21 +233,125 @@ func NewAppRunnerStackInputProps() *AppRunnerStackInputProps {
 			SubnetID2: "subnet-xxxxxxxxxxxxxxx", // Your Subnet ID
 		},
 		SourceConfigurationProps: &SourceConfigurationProps{
-			RepositoryUrl:  "https://github.com/go-to-k/go-cdk-go-managed-apprunner",
-			BranchName:     "master",
-			BuildCommand:   "go install ./app/...",
-			StartCommand:   "go run app/main.go",
-			Port:           8080,
-			ConnectionName: "AppRunnerConnection",
+			SourceType:          SourceTypeCode,
+			ConfigurationSource: ConfigurationSourceApi,
+			RepositoryUrl:       "https://github.com/go-to-k/go-cdk-go-managed-apprunner",
+			BranchName:          "master",
+			SourceDirectory:     "app",
+			Runtime:             RuntimeGo1,
+			BuildCommand:        "go install ./...",
+			StartCommand:        "go run main.go",
+			Port:                8080,
+			Environment:         map[string]string{},
+			ConnectionName:      "AppRunnerConnection",
+			ImageSourceProps: &ImageSourceProps{
+				EcrRepositoryName: "", // Your ECR repository name if you use an existing image
+				ImageTag:          "latest",
+				Directory:         "app",
+				Dockerfile:        "Dockerfile",
+			},
 		},
 		InstanceConfigurationProps: &InstanceConfigurationProps{
 			Cpu:    "1 vCPU",
 			Memory: "2 GB",
 		},
+		InstanceRoleGrantProps: &InstanceRoleGrantProps{
+			S3Buckets: []*ResourceGrantProps{
+				// {Name: "my-bucket", Access: AccessRead},
+			},
+			DynamoDBTables: []*ResourceGrantProps{
+				// {Name: "my-table", Access: AccessReadWrite},
+			},
+			SqsQueues: []*ResourceGrantProps{
+				// {Name: "my-queue", Access: AccessWrite},
+			},
+			SsmParameterPaths: []*ResourceGrantProps{
+				// {Name: "/go-cdk-go-managed-apprunner/dev", Access: AccessRead},
+			},
+		},
 		AutoScalingConfigurationArnProps: &AutoScalingConfigurationArnProps{
 			MaxConcurrency: 50,
 			MaxSize:        3,
 			MinSize:        1,
 		},
+		AutoScalingConfigurationResource: AutoScalingConfigurationResourceCustom,
+		ObservabilityConfigurationProps: &ObservabilityConfigurationProps{
+			TraceEnabled: true, // Send traces to AWS X-Ray
+		},
+		MonitoringProps: &MonitoringProps{
+			Http5xxThreshold:      10,
+			LatencyP99ThresholdMs: 1000,
+			NotificationEmails:    []string{}, // Your E-mail addresses for alarm notifications
+		},
+		NotificationProps: &NotificationProps{
+			Enabled:              false,
+			WebhookUrlSecretName: "",         // Name of your Secrets Manager secret with the Slack Incoming Webhook URL
+			NotificationEmails:   []string{}, // Your E-mail addresses for event notifications
+		},
+		ServiceProps: []*ServiceProps{
+			// {
+			// 	Name:            "Api",
+			// 	SourceDirectory: "services/api",
+			// 	BuildCommand:    "go install ./...",
+			// 	StartCommand:    "go run main.go",
+			// 	Port:            8080,
+			// 	Environment:     map[string]string{},
+			// 	InstanceConfigurationProps: &InstanceConfigurationProps{
+			// 		Cpu:    "0.25 vCPU",
+			// 		Memory: "0.5 GB",
+			// 	},
+			// 	AutoScalingConfigurationArnProps: &AutoScalingConfigurationArnProps{
+			// 		MaxConcurrency: 100,
+			// 		MaxSize:        2,
+			// 		MinSize:        1,
+			// 	},
+			// },
+		},
+		ScalingProfileProps: []*ScalingProfileProps{
+			// {
+			// 	Name:             "business-hours",
+			// 	Schedule:         "cron(0 9 ? * MON-FRI *)",
+			// 	ScheduleTimezone: "Asia/Tokyo",
+			// 	AutoScalingConfigurationArnProps: &AutoScalingConfigurationArnProps{
+			// 		MaxConcurrency: 50,
+			// 		MaxSize:        3,
+			// 		MinSize:        3,
+			// 	},
+			// },
+			// {
+			// 	Name:             "nights",
+			// 	Schedule:         "cron(0 21 * * ? *)",
+			// 	ScheduleTimezone: "Asia/Tokyo",
+			// 	AutoScalingConfigurationArnProps: &AutoScalingConfigurationArnProps{
+			// 		MaxConcurrency: 50,
+			// 		MaxSize:        3,
+			// 		MinSize:        1,
+			// 	},
+			// },
+		},
+		GarbageCollectionProps: &GarbageCollectionProps{
+			Enabled:          false,
+			Schedule:         "cron(0 3 ? * SUN *)",
+			ScheduleTimezone: "Asia/Tokyo",
+			DryRun:           true,
+			Allowlist:        []string{},
+			IncludeUntagged:  false,
+		},
+		Tags: map[string]string{
+			// "CostCenter": "1234",
+			// "Team":       "platform",
+		},
+		PauseResumeScheduleProps: map[string]*PauseResumeScheduleProps{
+			// "dev": {
+			// 	PauseSchedule:    "cron(0 22 ? * MON-FRI *)",
+			// 	ResumeSchedule:   "cron(0 8 ? * MON-FRI *)",
+			// 	ScheduleTimezone: "Asia/Tokyo",
+			// },
+			// "stg": {
+			// 	PauseSchedule:    "cron(0 22 ? * MON-FRI *)",
+			// 	ResumeSchedule:   "cron(0 8 ? * MON-FRI *)",
+			// 	ScheduleTimezone: "Asia/Tokyo",
+			// },
+		},
 	}
 }
@@ -0,0 +1,94 @@
+package input
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Managed runtimes of App Runner
+const (
+	RuntimeCorretto8  = "CORRETTO_8"
+	RuntimeCorretto11 = "CORRETTO_11"
+	RuntimeDotnet6    = "DOTNET_6"
+	RuntimeGo1        = "GO_1"
+	RuntimeNodejs12   = "NODEJS_12"
+	RuntimeNodejs14   = "NODEJS_14"
+	RuntimeNodejs16   = "NODEJS_16"
+	RuntimeNodejs18   = "NODEJS_18"
+	RuntimePhp81      = "PHP_81"
+	RuntimePython3    = "PYTHON_3"
+	RuntimePython311  = "PYTHON_311"
+	RuntimeRuby31     = "RUBY_31"
+)
+
+// portPlaceholder in StartCommand of Runtime is replaced by Port of the service.
+const portPlaceholder = "{port}"
+
+// Runtime is a managed runtime with its name in apprunner.yaml and the default build and start commands.
+type Runtime struct {
+	ConfigFileRuntime string
+	BuildCommand      string
+	StartCommand      string
+}
+
+var runtimes = map[string]*Runtime{
+	RuntimeCorretto8:  {ConfigFileRuntime: "corretto8", BuildCommand: "mvn clean package", StartCommand: "java -jar target/app.jar"},
+	RuntimeCorretto11: {ConfigFileRuntime: "corretto11", BuildCommand: "mvn clean package", StartCommand: "java -jar target/app.jar"},
+	RuntimeDotnet6:    {ConfigFileRuntime: "dotnet6", BuildCommand: "dotnet publish -c Release -o out", StartCommand: "dotnet out/app.dll"},
+	RuntimeGo1:        {ConfigFileRuntime: "go1", BuildCommand: "go build -o main .", StartCommand: "./main"},
+	RuntimeNodejs12:   {ConfigFileRuntime: "nodejs12", BuildCommand: "npm install", StartCommand: "npm start"},
+	RuntimeNodejs14:   {ConfigFileRuntime: "nodejs14", BuildCommand: "npm install", StartCommand: "npm start"},
+	RuntimeNodejs16:   {ConfigFileRuntime: "nodejs16", BuildCommand: "npm install", StartCommand: "npm start"},
+	RuntimeNodejs18:   {ConfigFileRuntime: "nodejs18", BuildCommand: "npm install", StartCommand: "npm start"},
+	RuntimePhp81:      {ConfigFileRuntime: "php81", BuildCommand: "composer install --no-dev", StartCommand: "php -S 0.0.0.0:" + portPlaceholder},
+	RuntimePython3:    {ConfigFileRuntime: "python3", BuildCommand: "pip install -r requirements.txt", StartCommand: "python app.py"},
+	RuntimePython311:  {ConfigFileRuntime: "python311", BuildCommand: "pip3 install -r requirements.txt", StartCommand: "python3 app.py"},
+	RuntimeRuby31:     {ConfigFileRuntime: "ruby31", BuildCommand: "bundle install", StartCommand: "bundle exec rackup --host 0.0.0.0 -p " + portPlaceholder},
+}
+
+// RuntimeNames returns the names of the supported managed runtimes in order.
+func RuntimeNames() []string {
+	names := make([]string, 0, len(runtimes))
+	for name := range runtimes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func LookupRuntime(name string) (*Runtime, error) {
+	runtime, ok := runtimes[name]
+	if !ok {
+		return nil, fmt.Errorf("Runtime must be one of %s: %s", strings.Join(RuntimeNames(), ", "), name)
+	}
+	return runtime, nil
+}
+
+// Resolve returns a copy of the props with the runtime (GO_1 if empty) and the port validated
+// and the empty BuildCommand and StartCommand set to the defaults of the runtime.
+func (p *SourceConfigurationProps) Resolve() (*SourceConfigurationProps, error) {
+	resolved := *p
+	if resolved.Port < 1 || resolved.Port > 65535 {
+		return nil, fmt.Errorf("Port must be between 1 and 65535: %d", resolved.Port)
+	}
+
+	if resolved.Runtime == "" {
+		resolved.Runtime = RuntimeGo1
+	}
+
+	runtime, err := LookupRuntime(resolved.Runtime)
+	if err != nil {
+		return nil, err
+	}
+
+	if resolved.BuildCommand == "" {
+		resolved.BuildCommand = runtime.BuildCommand
+	}
+	if resolved.StartCommand == "" {
+		resolved.StartCommand = strings.ReplaceAll(runtime.StartCommand, portPlaceholder, strconv.Itoa(resolved.Port))
+	}
+
+	return &resolved, nil
+}
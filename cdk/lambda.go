@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awss3assets"
+	"github.com/aws/constructs-go/constructs/v10"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// repositoryRoot is the root of the Go workspace, relative to the cdk directory.
+const repositoryRoot = "../"
+
+// goBundlingImage is used only when the Go toolchain of the host cannot build the function.
+const goBundlingImage = "public.ecr.aws/docker/library/golang:1.20"
+
+// goLocalBundling builds the bootstrap binary of the function with the Go toolchain of the host.
+type goLocalBundling struct {
+	entry  string
+	goarch string
+}
+
+func (b *goLocalBundling) TryBundle(outputDir *string, options *awscdk.BundlingOptions) *bool {
+	cmd := exec.Command("go", goBuildArgs(filepath.Join(*outputDir, "bootstrap"), b.entry)...)
+	cmd.Dir = repositoryRoot
+	cmd.Env = append(os.Environ(), goBuildEnv(b.goarch)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Local bundling of %s failed, so it is bundled with Docker: %v\n", b.entry, err)
+		return jsii.Bool(false)
+	}
+	return jsii.Bool(true)
+}
+
+func goBuildArgs(output string, entry string) []string {
+	return []string{"build", "-tags", "lambda.norpc", "-trimpath", "-ldflags", "-s -w", "-o", output, entry}
+}
+
+func goBuildEnv(goarch string) []string {
+	return []string{"GOOS=linux", "GOARCH=" + goarch, "CGO_ENABLED=0"}
+}
+
+// newGoFunction creates a Lambda function on the provided.al2023 runtime built from a main package of the custom module.
+// The entry is a path from the repository root, e.g. "./custom/notification".
+// The architecture is arm64 unless props.Architecture is set.
+func newGoFunction(scope constructs.Construct, id string, entry string, props *awslambda.FunctionProps) awslambda.Function {
+	if props.Architecture == nil {
+		props.Architecture = awslambda.Architecture_ARM_64()
+	}
+	goarch := "arm64"
+	if *props.Architecture.Name() == *awslambda.Architecture_X86_64().Name() {
+		goarch = "amd64"
+	}
+
+	dockerCommand := "env " + shellJoin(append(goBuildEnv(goarch), append([]string{"go"}, goBuildArgs("/asset-output/bootstrap", entry)...)...))
+
+	props.Runtime = awslambda.NewRuntime(jsii.String("provided.al2023"), awslambda.RuntimeFamily_OTHER, nil)
+	props.Handler = jsii.String("bootstrap")
+	props.Code = awslambda.AssetCode_FromAsset(jsii.String(repositoryRoot), &awss3assets.AssetOptions{
+		Bundling: &awscdk.BundlingOptions{
+			Local: &goLocalBundling{
+				entry:  entry,
+				goarch: goarch,
+			},
+			Image:   awscdk.DockerImage_FromRegistry(jsii.String(goBundlingImage)),
+			Command: jsii.Strings("bash", "-c", dockerCommand),
+			User:    jsii.String("root"),
+		},
+	})
+
+	return awslambda.NewFunction(scope, jsii.String(id), props)
+}
+
+// shellJoin quotes the arguments with single quotes for the bash command of Docker bundling.
+func shellJoin(args []string) string {
+	quoted := ""
+	for i, arg := range args {
+		if i > 0 {
+			quoted += " "
+		}
+		quoted += "'" + arg + "'"
+	}
+	return quoted
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"go-cdk-go-managed-apprunner/cdk/input"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatch"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awscloudwatchactions"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssnssubscriptions"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// customResourceMetricsNamespace is the namespace of the EMF metrics of the custom resource Lambda (custom/metrics),
+// given to it by METRICS_NAMESPACE.
+const customResourceMetricsNamespace = "AppRunnerCustomResource"
+
+type appRunnerService struct {
+	id                          string
+	serviceArn                  *string
+	autoScalingProps            *input.AutoScalingConfigurationArnProps
+	ownAutoScalingConfiguration bool
+}
+
+// The metrics of AppRunner are dimensioned by ServiceName and ServiceID,
+// which are taken from the service ARN (arn:aws:apprunner:region:account:service/name/id).
+func (s *appRunnerService) dimensions() *map[string]*string {
+	arnParts := awscdk.Fn_Split(jsii.String("/"), s.serviceArn, jsii.Number(3))
+	return &map[string]*string{
+		"ServiceName": awscdk.Fn_Select(jsii.Number(1), arnParts),
+		"ServiceID":   awscdk.Fn_Select(jsii.Number(2), arnParts),
+	}
+}
+
+func (s *appRunnerService) metric(metricName string, statistic string) awscloudwatch.Metric {
+	return awscloudwatch.NewMetric(&awscloudwatch.MetricProps{
+		Namespace:     jsii.String("AWS/AppRunner"),
+		MetricName:    jsii.String(metricName),
+		DimensionsMap: s.dimensions(),
+		Statistic:     jsii.String(statistic),
+		Period:        awscdk.Duration_Minutes(jsii.Number(1)),
+		Label:         jsii.String(s.id),
+	})
+}
+
+// The alarms and the widget of the custom resource are created only with customResourceLambda, the custom resource Lambda in the stack.
+func createMonitoring(stack awscdk.Stack, services []*appRunnerService, inputProps *input.AppRunnerStackInputProps, customResourceLambda awslambda.Function) awssns.Topic {
+	monitoringProps := inputProps.MonitoringProps
+	autoScalingProps := inputProps.AutoScalingConfigurationArnProps
+
+	alarmTopic := awssns.NewTopic(stack, jsii.String("AlarmTopic"), &awssns.TopicProps{})
+	for _, email := range monitoringProps.NotificationEmails {
+		alarmTopic.AddSubscription(awssnssubscriptions.NewEmailSubscription(jsii.String(email), nil))
+	}
+	alarmAction := awscloudwatchactions.NewSnsAction(alarmTopic)
+
+	http5xxMetrics := []awscloudwatch.IMetric{}
+	latencyMetrics := []awscloudwatch.IMetric{}
+	activeInstancesMetrics := []awscloudwatch.IMetric{}
+	concurrencyMetrics := []awscloudwatch.IMetric{}
+
+	for _, service := range services {
+		http5xxMetric := service.metric("5xxStatusResponses", "Sum")
+		latencyMetric := service.metric("RequestLatency", "p99")
+		activeInstancesMetric := service.metric("ActiveInstances", "Maximum")
+
+		// Concurrency is the number of requests of the whole service,
+		// so it is divided by the number of instances to compare with MaxConcurrency per instance.
+		concurrencyMetric := awscloudwatch.NewMathExpression(&awscloudwatch.MathExpressionProps{
+			Expression: jsii.String("concurrency / IF(instances > 0, instances, 1)"),
+			UsingMetrics: &map[string]awscloudwatch.IMetric{
+				"concurrency": service.metric("Concurrency", "Maximum"),
+				"instances":   activeInstancesMetric,
+			},
+			Period: awscdk.Duration_Minutes(jsii.Number(1)),
+			Label:  jsii.String(service.id),
+		})
+
+		alarms := []awscloudwatch.Alarm{
+			awscloudwatch.NewAlarm(stack, jsii.String(service.id+"5xxAlarm"), &awscloudwatch.AlarmProps{
+				Metric:             http5xxMetric,
+				Threshold:          jsii.Number(float64(monitoringProps.Http5xxThreshold)),
+				ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+				EvaluationPeriods:  jsii.Number(3),
+				TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+				AlarmDescription:   jsii.String("5xx responses of " + service.id),
+			}),
+			awscloudwatch.NewAlarm(stack, jsii.String(service.id+"LatencyAlarm"), &awscloudwatch.AlarmProps{
+				Metric:             latencyMetric,
+				Threshold:          jsii.Number(float64(monitoringProps.LatencyP99ThresholdMs)),
+				ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_THRESHOLD,
+				EvaluationPeriods:  jsii.Number(3),
+				TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+				AlarmDescription:   jsii.String("p99 request latency (ms) of " + service.id),
+			}),
+			awscloudwatch.NewAlarm(stack, jsii.String(service.id+"ActiveInstancesAlarm"), &awscloudwatch.AlarmProps{
+				Metric:             activeInstancesMetric,
+				Threshold:          jsii.Number(float64(service.autoScalingProps.MaxSize)),
+				ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+				EvaluationPeriods:  jsii.Number(3),
+				TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+				AlarmDescription:   jsii.String("Active instances of " + service.id + " reached MaxSize"),
+			}),
+			awscloudwatch.NewAlarm(stack, jsii.String(service.id+"ConcurrencyAlarm"), &awscloudwatch.AlarmProps{
+				Metric:             concurrencyMetric,
+				Threshold:          jsii.Number(float64(service.autoScalingProps.MaxConcurrency)),
+				ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+				EvaluationPeriods:  jsii.Number(3),
+				TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+				AlarmDescription:   jsii.String("Concurrency per instance of " + service.id + " reached MaxConcurrency"),
+			}),
+		}
+		for _, alarm := range alarms {
+			alarm.AddAlarmAction(alarmAction)
+		}
+
+		http5xxMetrics = append(http5xxMetrics, http5xxMetric)
+		latencyMetrics = append(latencyMetrics, latencyMetric)
+		activeInstancesMetrics = append(activeInstancesMetrics, activeInstancesMetric)
+		concurrencyMetrics = append(concurrencyMetrics, concurrencyMetric)
+	}
+
+	// The annotations of the saturation widgets are the thresholds of the stack's AutoScalingConfiguration.
+	dashboard := awscloudwatch.NewDashboard(stack, jsii.String("Dashboard"), &awscloudwatch.DashboardProps{
+		DashboardName: jsii.String(*stack.StackName() + "-AppRunner"),
+	})
+	dashboard.AddWidgets(
+		graphWidget("5xxStatusResponses", http5xxMetrics, float64(monitoringProps.Http5xxThreshold)),
+		graphWidget("RequestLatency p99 (ms)", latencyMetrics, float64(monitoringProps.LatencyP99ThresholdMs)),
+	)
+	dashboard.AddWidgets(
+		graphWidget("ActiveInstances", activeInstancesMetrics, float64(autoScalingProps.MaxSize)),
+		graphWidget("Concurrency per instance", concurrencyMetrics, float64(autoScalingProps.MaxConcurrency)),
+	)
+
+	if customResourceLambda != nil {
+		// A failure of the custom resource leaves the stack in a failed or rolled back state,
+		// and its Reason points at the logs of the request.
+		customResourceFailuresMetric := customResourceMetric(stack, "Failures", "Sum")
+		customResourceFailuresAlarm := awscloudwatch.NewAlarm(stack, jsii.String("CustomResourceFailuresAlarm"), &awscloudwatch.AlarmProps{
+			Metric:             customResourceFailuresMetric,
+			Threshold:          jsii.Number(1),
+			ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+			EvaluationPeriods:  jsii.Number(1),
+			TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+			AlarmDescription:   jsii.String("Failed requests of the AutoScalingConfiguration custom resource"),
+		})
+		customResourceFailuresAlarm.AddAlarmAction(alarmAction)
+
+		// A timeout or a panic of the Lambda writes no Failures metric, but it is counted in Errors.
+		customResourceErrorsMetric := customResourceLambda.MetricErrors(&awscloudwatch.MetricOptions{
+			Period: awscdk.Duration_Minutes(jsii.Number(5)),
+			Label:  jsii.String("LambdaErrors"),
+		})
+		customResourceErrorsAlarm := awscloudwatch.NewAlarm(stack, jsii.String("CustomResourceLambdaErrorsAlarm"), &awscloudwatch.AlarmProps{
+			Metric:             customResourceErrorsMetric,
+			Threshold:          jsii.Number(1),
+			ComparisonOperator: awscloudwatch.ComparisonOperator_GREATER_THAN_OR_EQUAL_TO_THRESHOLD,
+			EvaluationPeriods:  jsii.Number(1),
+			TreatMissingData:   awscloudwatch.TreatMissingData_NOT_BREACHING,
+			AlarmDescription:   jsii.String("Errors of the Lambda of the AutoScalingConfiguration custom resource"),
+		})
+		customResourceErrorsAlarm.AddAlarmAction(alarmAction)
+
+		dashboard.AddWidgets(
+			awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+				Title: jsii.String("AutoScalingConfiguration custom resource"),
+				Left: &[]awscloudwatch.IMetric{
+					customResourceMetric(stack, "ServicesUpdated", "Sum"),
+					customResourceFailuresMetric,
+					customResourceMetric(stack, "Retries", "Sum"),
+					customResourceErrorsMetric,
+				},
+				Right: &[]awscloudwatch.IMetric{
+					customResourceMetric(stack, "Duration", "Maximum"),
+				},
+				Width: jsii.Number(24),
+			}),
+		)
+	}
+
+	return alarmTopic
+}
+
+// customResourceMetric returns the metric of the custom resource requests of the stack,
+// which custom/metrics writes with the dimensions [StackName, RequestType] and [StackName].
+func customResourceMetric(stack awscdk.Stack, metricName string, statistic string) awscloudwatch.Metric {
+	return awscloudwatch.NewMetric(&awscloudwatch.MetricProps{
+		Namespace:  jsii.String(customResourceMetricsNamespace),
+		MetricName: jsii.String(metricName),
+		DimensionsMap: &map[string]*string{
+			"StackName": stack.StackName(),
+		},
+		Statistic: jsii.String(statistic),
+		Period:    awscdk.Duration_Minutes(jsii.Number(5)),
+		Label:     jsii.String(metricName),
+	})
+}
+
+func graphWidget(title string, metrics []awscloudwatch.IMetric, threshold float64) awscloudwatch.GraphWidget {
+	return awscloudwatch.NewGraphWidget(&awscloudwatch.GraphWidgetProps{
+		Title: jsii.String(title),
+		Left:  &metrics,
+		LeftAnnotations: &[]*awscloudwatch.HorizontalAnnotation{
+			{
+				Value: jsii.Number(threshold),
+				Label: jsii.String("Alarm threshold"),
+			},
+		},
+		Width: jsii.Number(12),
+	})
+}
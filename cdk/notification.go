@@ -0,0 +1,57 @@
+package main
+
+import (
+	"go-cdk-go-managed-apprunner/cdk/input"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awseventstargets"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssecretsmanager"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssns"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awssnssubscriptions"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func createNotification(stack awscdk.Stack, services []*appRunnerService, notificationProps *input.NotificationProps) {
+	notificationTopic := awssns.NewTopic(stack, jsii.String("NotificationTopic"), &awssns.TopicProps{})
+	for _, email := range notificationProps.NotificationEmails {
+		notificationTopic.AddSubscription(awssnssubscriptions.NewEmailSubscription(jsii.String(email), nil))
+	}
+
+	// The webhook URL is a credential, so only the secret name goes into the environment and the Lambda reads it at runtime.
+	environment := map[string]*string{
+		"TOPIC_ARN": notificationTopic.TopicArn(),
+	}
+	if notificationProps.WebhookUrlSecretName != "" {
+		environment["WEBHOOK_URL_SECRET_ID"] = jsii.String(notificationProps.WebhookUrlSecretName)
+	}
+
+	notificationLambda := newGoFunction(stack, "NotificationLambda", "./custom/notification", &awslambda.FunctionProps{
+		Timeout:     awscdk.Duration_Seconds(jsii.Number(30)),
+		Environment: &environment,
+	})
+	notificationTopic.GrantPublish(notificationLambda)
+
+	if notificationProps.WebhookUrlSecretName != "" {
+		webhookUrlSecret := awssecretsmanager.Secret_FromSecretNameV2(stack, jsii.String("NotificationWebhookUrlSecret"), jsii.String(notificationProps.WebhookUrlSecretName))
+		webhookUrlSecret.GrantRead(notificationLambda, nil)
+	}
+
+	serviceArns := []*string{}
+	for _, service := range services {
+		serviceArns = append(serviceArns, service.serviceArn)
+	}
+
+	notificationRule := awsevents.NewRule(stack, jsii.String("NotificationRule"), &awsevents.RuleProps{
+		EventPattern: &awsevents.EventPattern{
+			Source: jsii.Strings("aws.apprunner"),
+			DetailType: jsii.Strings(
+				"AppRunner Service Status Change",
+				"AppRunner Service Operation Status Change",
+			),
+			Resources: &serviceArns,
+		},
+	})
+	notificationRule.AddTarget(awseventstargets.NewLambdaFunction(notificationLambda, nil))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"go-cdk-go-managed-apprunner/cdk/input"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsevents"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsscheduler"
+	"github.com/aws/jsii-runtime-go"
+)
+
+func createPauseResumeSchedule(stack awscdk.Stack, services []*appRunnerService, pauseResumeScheduleProps *input.PauseResumeScheduleProps) {
+	serviceArns := []*string{}
+	for _, service := range services {
+		serviceArns = append(serviceArns, service.serviceArn)
+	}
+
+	pauseResumeLambda := newGoFunction(stack, "PauseResumeLambda", "./custom/pauseresume", &awslambda.FunctionProps{
+		Timeout: awscdk.Duration_Seconds(jsii.Number(900)),
+		InitialPolicy: &[]awsiam.PolicyStatement{
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions: &[]*string{
+					jsii.String("apprunner:DescribeService"),
+					jsii.String("apprunner:PauseService"),
+					jsii.String("apprunner:ResumeService"),
+					jsii.String("apprunner:ListOperations"),
+				},
+				Resources: &serviceArns,
+			}),
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions: &[]*string{
+					jsii.String("cloudformation:DescribeStacks"),
+				},
+				Resources: &[]*string{
+					stack.StackId(),
+				},
+			}),
+		},
+	})
+	awsevents.EventBus_FromEventBusName(stack, jsii.String("DefaultEventBus"), jsii.String("default")).GrantPutEventsTo(pauseResumeLambda)
+
+	schedulerRole := awsiam.NewRole(stack, jsii.String("PauseResumeSchedulerRole"), &awsiam.RoleProps{
+		AssumedBy: awsiam.NewServicePrincipal(jsii.String("scheduler.amazonaws.com"), nil),
+	})
+	pauseResumeLambda.GrantInvoke(schedulerRole)
+
+	schedules := map[string]string{
+		"pause":  pauseResumeScheduleProps.PauseSchedule,
+		"resume": pauseResumeScheduleProps.ResumeSchedule,
+	}
+	for _, action := range []string{"pause", "resume"} {
+		awsscheduler.NewCfnSchedule(stack, jsii.String("PauseResumeSchedule-"+action), &awsscheduler.CfnScheduleProps{
+			ScheduleExpression:         jsii.String(schedules[action]),
+			ScheduleExpressionTimezone: jsii.String(pauseResumeScheduleProps.ScheduleTimezone),
+			FlexibleTimeWindow: &awsscheduler.CfnSchedule_FlexibleTimeWindowProperty{
+				Mode: jsii.String("OFF"),
+			},
+			Target: &awsscheduler.CfnSchedule_TargetProperty{
+				Arn:     pauseResumeLambda.FunctionArn(),
+				RoleArn: schedulerRole.RoleArn(),
+				Input: stack.ToJsonString(map[string]interface{}{
+					"Action":    action,
+					"StackName": *stack.StackName(),
+				}, nil),
+			},
+		})
+	}
+}
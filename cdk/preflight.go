@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"go-cdk-go-managed-apprunner/cdk/input"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// parseVcpu converts Cpu of InstanceConfigurationProps ("1 vCPU" or "1024") to the number of vCPUs.
+func parseVcpu(cpu string) (float64, error) {
+	cpu = strings.TrimSpace(cpu)
+
+	if strings.HasSuffix(strings.ToLower(cpu), "vcpu") {
+		vcpu, err := strconv.ParseFloat(strings.TrimSpace(cpu[:len(cpu)-len("vcpu")]), 64)
+		if err != nil {
+			return 0, fmt.Errorf("Cpu Convert Error: %s", cpu)
+		}
+		return vcpu, nil
+	}
+
+	units, err := strconv.Atoi(cpu)
+	if err != nil {
+		return 0, fmt.Errorf("Cpu Convert Error: %s", cpu)
+	}
+	return float64(units) / 1024, nil
+}
+
+// worstCaseMaxSize is the largest MaxSize of the stack and the scaling profiles.
+func worstCaseMaxSize(inputProps *input.AppRunnerStackInputProps) int {
+	maxSize := inputProps.AutoScalingConfigurationArnProps.MaxSize
+	for _, scalingProfile := range inputProps.ScalingProfileProps {
+		if scalingProfile.AutoScalingConfigurationArnProps.MaxSize > maxSize {
+			maxSize = scalingProfile.AutoScalingConfigurationArnProps.MaxSize
+		}
+	}
+	return maxSize
+}
+
+// requiredVcpu is the worst-case vCPU usage of all the services (vCPU x MaxSize) and its breakdown per service.
+// The services on the stack's AutoScalingConfiguration can be switched to any scaling profile.
+func requiredVcpu(inputProps *input.AppRunnerStackInputProps) (float64, []string, error) {
+	type serviceVcpu struct {
+		name    string
+		cpu     string
+		maxSize int
+	}
+
+	sharedMaxSize := worstCaseMaxSize(inputProps)
+	services := []serviceVcpu{
+		{name: "L2", cpu: inputProps.InstanceConfigurationProps.Cpu, maxSize: sharedMaxSize},
+		{name: "L1", cpu: inputProps.InstanceConfigurationProps.Cpu, maxSize: sharedMaxSize},
+	}
+	for _, serviceProps := range inputProps.ServiceProps {
+		service := serviceVcpu{name: serviceProps.Name, cpu: inputProps.InstanceConfigurationProps.Cpu, maxSize: sharedMaxSize}
+		if serviceProps.InstanceConfigurationProps != nil {
+			service.cpu = serviceProps.InstanceConfigurationProps.Cpu
+		}
+		if serviceProps.AutoScalingConfigurationArnProps != nil {
+			service.maxSize = serviceProps.AutoScalingConfigurationArnProps.MaxSize
+		}
+		services = append(services, service)
+	}
+
+	total := 0.0
+	breakdown := []string{}
+	for _, service := range services {
+		vcpu, err := parseVcpu(service.cpu)
+		if err != nil {
+			return 0, nil, err
+		}
+		total += vcpu * float64(service.maxSize)
+		breakdown = append(breakdown, fmt.Sprintf("%s: Cpu %s x MaxSize %d", service.name, service.cpu, service.maxSize))
+	}
+
+	return total, breakdown, nil
+}
+
+// createVcpuQuotaPreflight fails the deployment before the services are updated
+// if the worst-case vCPU usage exceeds the Fargate On-Demand vCPU quota.
+func createVcpuQuotaPreflight(stack awscdk.Stack, inputProps *input.AppRunnerStackInputProps) awscdk.CustomResource {
+	totalVcpu, breakdown, err := requiredVcpu(inputProps)
+	if err != nil {
+		panic(err)
+	}
+
+	preflightLambda := newGoFunction(stack, "VcpuQuotaPreflightLambda", "./custom/preflight", &awslambda.FunctionProps{
+		Timeout: awscdk.Duration_Seconds(jsii.Number(60)),
+		InitialPolicy: &[]awsiam.PolicyStatement{
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions: &[]*string{
+					jsii.String("servicequotas:GetServiceQuota"),
+				},
+				Resources: &[]*string{
+					stack.FormatArn(&awscdk.ArnComponents{
+						Service:      jsii.String("servicequotas"),
+						Resource:     jsii.String("fargate"),
+						ResourceName: jsii.String("L-3032A538"),
+						ArnFormat:    awscdk.ArnFormat_SLASH_RESOURCE_NAME,
+					}),
+				},
+			}),
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions: &[]*string{
+					jsii.String("servicequotas:GetAWSDefaultServiceQuota"),
+				},
+				Resources: &[]*string{
+					jsii.String("*"),
+				},
+			}),
+		},
+	})
+
+	return awscdk.NewCustomResource(stack, jsii.String("VcpuQuotaPreflight"), &awscdk.CustomResourceProps{
+		ResourceType: jsii.String("Custom::VcpuQuotaPreflight"),
+		Properties: &map[string]interface{}{
+			"RequiredVcpu": strconv.FormatFloat(totalVcpu, 'f', -1, 64),
+			"Breakdown":    strings.Join(breakdown, ", "),
+		},
+		ServiceToken: preflightLambda.FunctionArn(),
+	})
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"go-cdk-go-managed-apprunner/cdk/input"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awslambda"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsscheduler"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// createScheduledScaling switches the services on the stack's AutoScalingConfiguration (serviceArnExportNames, nil for all) between the profiles.
+func createScheduledScaling(
+	stack awscdk.Stack,
+	services []*appRunnerService,
+	serviceArnExportNames []string,
+	serviceToken *string,
+	scalingProfiles []*input.ScalingProfileProps,
+	tags map[string]string,
+) {
+	serviceArns := []*string{}
+	for _, service := range services {
+		serviceArns = append(serviceArns, service.serviceArn)
+	}
+
+	scheduledScalingLambda := newGoFunction(stack, "ScheduledScalingLambda", "./custom/scheduledscaling", &awslambda.FunctionProps{
+		Timeout: awscdk.Duration_Seconds(jsii.Number(900)),
+		InitialPolicy: &[]awsiam.PolicyStatement{
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions: &[]*string{
+					jsii.String("apprunner:UpdateService"),
+					jsii.String("apprunner:ListOperations"),
+				},
+				Resources: &serviceArns,
+			}),
+			awsiam.NewPolicyStatement(&awsiam.PolicyStatementProps{
+				Actions: &[]*string{
+					jsii.String("cloudformation:DescribeStacks"),
+				},
+				Resources: &[]*string{
+					stack.StackId(),
+				},
+			}),
+		},
+	})
+
+	schedulerRole := awsiam.NewRole(stack, jsii.String("ScheduledScalingSchedulerRole"), &awsiam.RoleProps{
+		AssumedBy: awsiam.NewServicePrincipal(jsii.String("scheduler.amazonaws.com"), nil),
+	})
+	scheduledScalingLambda.GrantInvoke(schedulerRole)
+
+	for _, scalingProfile := range scalingProfiles {
+		autoScalingConfigurationArn := newAutoScalingConfiguration(
+			stack,
+			"AutoScalingConfiguration-"+scalingProfile.Name,
+			*stack.StackName()+"-"+scalingProfile.Name,
+			scalingProfile.AutoScalingConfigurationArnProps,
+			serviceToken,
+			serviceArnExportNames,
+			tags,
+		)
+
+		awsscheduler.NewCfnSchedule(stack, jsii.String("ScalingSchedule-"+scalingProfile.Name), &awsscheduler.CfnScheduleProps{
+			ScheduleExpression:         jsii.String(scalingProfile.Schedule),
+			ScheduleExpressionTimezone: jsii.String(scalingProfile.ScheduleTimezone),
+			FlexibleTimeWindow: &awsscheduler.CfnSchedule_FlexibleTimeWindowProperty{
+				Mode: jsii.String("OFF"),
+			},
+			Target: &awsscheduler.CfnSchedule_TargetProperty{
+				Arn:     scheduledScalingLambda.FunctionArn(),
+				RoleArn: schedulerRole.RoleArn(),
+				Input: stack.ToJsonString(map[string]interface{}{
+					"StackName":                   *stack.StackName(),
+					"ServiceArnExportNames":       serviceArnExportNames,
+					"AutoScalingConfigurationArn": autoScalingConfigurationArn,
+				}, nil),
+			},
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"go-cdk-go-managed-apprunner/cdk/input"
+	"regexp"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapprunner"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// Name of ServiceProps is used in the construct IDs, the export names and the AutoScalingConfiguration name.
+var serviceNamePattern = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+
+// serviceDefaults is what the additional services share with the L1 service.
+type serviceDefaults struct {
+	connectionArn               string
+	instanceRole                awsiam.IRole
+	vpcConnectorArn             *string
+	observabilityConfiguration  *awsapprunner.CfnService_ServiceObservabilityConfigurationProperty
+	autoScalingConfigurationArn *string
+	serviceToken                *string
+	preflight                   awscdk.CustomResource
+	tags                        map[string]string
+}
+
+func validateServiceProps(inputProps *input.AppRunnerStackInputProps) {
+	if len(inputProps.ServiceProps) == 0 {
+		return
+	}
+
+	if inputProps.SourceConfigurationProps.SourceType != input.SourceTypeCode {
+		panic(fmt.Errorf("ServiceProps is supported only with SourceType %s: %s", input.SourceTypeCode, inputProps.SourceConfigurationProps.SourceType))
+	}
+
+	names := map[string]bool{"L1": true, "L2": true}
+	for _, scalingProfile := range inputProps.ScalingProfileProps {
+		names[scalingProfile.Name] = true
+	}
+
+	for _, serviceProps := range inputProps.ServiceProps {
+		if !serviceNamePattern.MatchString(serviceProps.Name) {
+			panic(fmt.Errorf("Name of ServiceProps must be alphanumeric: %s", serviceProps.Name))
+		}
+		if names[serviceProps.Name] {
+			panic(fmt.Errorf("Name of ServiceProps must be unique among the services and the scaling profiles: %s", serviceProps.Name))
+		}
+		names[serviceProps.Name] = true
+
+		checkApprunnerYaml(serviceSourceConfigurationProps(inputProps, serviceProps), inputProps.TraceEnabled())
+	}
+}
+
+// serviceSourceConfigurationProps returns the source settings of the additional service with the defaults of the runtime.
+func serviceSourceConfigurationProps(inputProps *input.AppRunnerStackInputProps, serviceProps *input.ServiceProps) *input.SourceConfigurationProps {
+	sourceProps, err := inputProps.SourceConfigurationProps.ForService(serviceProps).Resolve()
+	if err != nil {
+		panic(fmt.Errorf("%s: %v", serviceProps.Name, err))
+	}
+	return sourceProps
+}
+
+// serviceArnExportName is the export name of the service ARN output, by which the Lambda functions find the service.
+func serviceArnExportName(stack awscdk.Stack, id string) string {
+	return *stack.StackName() + id + "ServiceArn"
+}
+
+// sharedAutoScalingExportNames returns the export names of the services on the stack's AutoScalingConfiguration,
+// or nil (all the services) if no service has its own one.
+func sharedAutoScalingExportNames(stack awscdk.Stack, inputProps *input.AppRunnerStackInputProps) []string {
+	exportNames := []string{
+		serviceArnExportName(stack, "AppRunnerServiceL2"),
+		serviceArnExportName(stack, "AppRunnerServiceL1"),
+	}
+	ownAutoScaling := false
+	for _, serviceProps := range inputProps.ServiceProps {
+		if serviceProps.AutoScalingConfigurationArnProps != nil {
+			ownAutoScaling = true
+			continue
+		}
+		exportNames = append(exportNames, serviceArnExportName(stack, "AppRunnerService"+serviceProps.Name))
+	}
+
+	if !ownAutoScaling {
+		return nil
+	}
+	return exportNames
+}
+
+// createAdditionalServices creates an L1 service for each ServiceProps from the same repository and connection.
+func createAdditionalServices(stack awscdk.Stack, inputProps *input.AppRunnerStackInputProps, defaults *serviceDefaults) []*appRunnerService {
+	services := []*appRunnerService{}
+
+	for _, serviceProps := range inputProps.ServiceProps {
+		id := "AppRunnerService" + serviceProps.Name
+		sourceProps := serviceSourceConfigurationProps(inputProps, serviceProps)
+
+		instanceConfigurationProps := inputProps.InstanceConfigurationProps
+		if serviceProps.InstanceConfigurationProps != nil {
+			instanceConfigurationProps = serviceProps.InstanceConfigurationProps
+		}
+
+		autoScalingProps := inputProps.AutoScalingConfigurationArnProps
+		autoScalingConfigurationArn := defaults.autoScalingConfigurationArn
+		if serviceProps.AutoScalingConfigurationArnProps != nil {
+			autoScalingProps = serviceProps.AutoScalingConfigurationArnProps
+			autoScalingConfigurationArn = newAutoScalingConfiguration(
+				stack,
+				id+"AutoScalingConfiguration",
+				*stack.StackName()+"-"+serviceProps.Name,
+				autoScalingProps,
+				defaults.serviceToken,
+				[]string{serviceArnExportName(stack, id)},
+				defaults.tags,
+			)
+		}
+
+		service := awsapprunner.NewCfnService(stack, jsii.String(id), &awsapprunner.CfnServiceProps{
+			SourceConfiguration: newL1SourceConfiguration(sourceProps, nil, defaults.connectionArn, withTracing(nil, defaults.observabilityConfiguration != nil)),
+			HealthCheckConfiguration: &awsapprunner.CfnService_HealthCheckConfigurationProperty{
+				Path:     jsii.String("/"),
+				Protocol: jsii.String("HTTP"),
+			},
+			InstanceConfiguration: &awsapprunner.CfnService_InstanceConfigurationProperty{
+				Cpu:             jsii.String(instanceConfigurationProps.Cpu),
+				Memory:          jsii.String(instanceConfigurationProps.Memory),
+				InstanceRoleArn: defaults.instanceRole.RoleArn(),
+			},
+			NetworkConfiguration: &awsapprunner.CfnService_NetworkConfigurationProperty{
+				EgressConfiguration: awsapprunner.CfnService_EgressConfigurationProperty{
+					EgressType:      jsii.String("VPC"),
+					VpcConnectorArn: defaults.vpcConnectorArn,
+				},
+			},
+			AutoScalingConfigurationArn: autoScalingConfigurationArn,
+		})
+		setSourceDirectory(service, sourceProps)
+		if defaults.observabilityConfiguration != nil {
+			service.SetObservabilityConfiguration(defaults.observabilityConfiguration)
+		}
+		service.Node().AddDependency(defaults.preflight)
+
+		services = append(services, &appRunnerService{
+			id:                          id,
+			serviceArn:                  service.AttrServiceArn(),
+			autoScalingProps:            autoScalingProps,
+			ownAutoScalingConfiguration: serviceProps.AutoScalingConfigurationArnProps != nil,
+		})
+	}
+
+	return services
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"fmt"
+	"go-cdk-go-managed-apprunner/cdk/apprunneryaml"
+	"go-cdk-go-managed-apprunner/cdk/input"
+	"sort"
+	"strconv"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsapprunner"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsecr"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsecrassets"
+	"github.com/aws/aws-cdk-go/awscdk/v2/awsiam"
+	apprunner "github.com/aws/aws-cdk-go/awscdkapprunneralpha/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// imageSource is the container image of the IMAGE source type,
+// either an existing ECR repository and tag or a Dockerfile built as a DockerImageAsset.
+type imageSource struct {
+	repository awsecr.IRepository
+	tag        *string
+	asset      awsecrassets.DockerImageAsset
+	imageUri   *string
+	accessRole awsiam.Role
+}
+
+func newImageSource(stack awscdk.Stack, imageSourceProps *input.ImageSourceProps) *imageSource {
+	source := &imageSource{}
+
+	if imageSourceProps.EcrRepositoryName != "" {
+		source.repository = awsecr.Repository_FromRepositoryName(stack, jsii.String("SourceEcrRepository"), jsii.String(imageSourceProps.EcrRepositoryName))
+		source.tag = jsii.String(imageSourceProps.ImageTag)
+		source.imageUri = source.repository.RepositoryUriForTag(source.tag)
+	} else {
+		source.asset = awsecrassets.NewDockerImageAsset(stack, jsii.String("SourceImageAsset"), &awsecrassets.DockerImageAssetProps{
+			Directory: jsii.String("../" + imageSourceProps.Directory),
+			File:      jsii.String(imageSourceProps.Dockerfile),
+		})
+		source.repository = source.asset.Repository()
+		source.imageUri = source.asset.ImageUri()
+	}
+
+	// The access role lets App Runner pull the image from ECR, which is also needed for auto deployments.
+	source.accessRole = awsiam.NewRole(stack, jsii.String("AppRunnerEcrAccessRole"), &awsiam.RoleProps{
+		AssumedBy: awsiam.NewServicePrincipal(jsii.String("build.apprunner.amazonaws.com"), nil),
+	})
+	source.repository.GrantPull(source.accessRole)
+
+	return source
+}
+
+// resolveSourceConfigurationProps validates the props and sets the defaults of the runtime.
+func resolveSourceConfigurationProps(sourceProps *input.SourceConfigurationProps, traceEnabled bool) *input.SourceConfigurationProps {
+	sourceProps, err := sourceProps.Resolve()
+	if err != nil {
+		panic(err)
+	}
+
+	if sourceProps.SourceType != input.SourceTypeCode && sourceProps.SourceType != input.SourceTypeImage {
+		panic(fmt.Errorf("SourceType must be %s or %s: %s", input.SourceTypeCode, input.SourceTypeImage, sourceProps.SourceType))
+	}
+
+	if sourceProps.ConfigurationSource != input.ConfigurationSourceApi && sourceProps.ConfigurationSource != input.ConfigurationSourceRepository {
+		panic(fmt.Errorf("ConfigurationSource must be %s or %s: %s", input.ConfigurationSourceApi, input.ConfigurationSourceRepository, sourceProps.ConfigurationSource))
+	}
+
+	checkApprunnerYaml(sourceProps, traceEnabled)
+
+	return sourceProps
+}
+
+// The committed apprunner.yaml must agree with the props, otherwise the services would run with different settings,
+// e.g. without TRACE_ENABLED the app would not export the traces to the ObservabilityConfiguration.
+func checkApprunnerYaml(sourceProps *input.SourceConfigurationProps, traceEnabled bool) {
+	if sourceProps.SourceType == input.SourceTypeCode && sourceProps.ConfigurationSource == input.ConfigurationSourceRepository {
+		if err := apprunneryaml.Check(apprunneryaml.Path(sourceProps.SourceDirectory), sourceProps, traceEnabled); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// setSourceDirectory sets SourceDirectory of the code repository, which is not supported by this CDK version.
+func setSourceDirectory(service awsapprunner.CfnService, sourceProps *input.SourceConfigurationProps) {
+	if sourceProps.SourceType == input.SourceTypeCode && sourceProps.SourceDirectory != "" {
+		service.AddPropertyOverride(jsii.String("SourceConfiguration.CodeRepository.SourceDirectory"), jsii.String(sourceProps.SourceDirectory))
+	}
+}
+
+// mergeEnvironment adds the service specific environment variables to the common ones of SourceConfigurationProps.
+func mergeEnvironment(sourceProps *input.SourceConfigurationProps, environment map[string]string) map[string]string {
+	merged := map[string]string{}
+	for key, value := range sourceProps.Environment {
+		merged[key] = value
+	}
+	for key, value := range environment {
+		merged[key] = value
+	}
+	return merged
+}
+
+func sortedKeys(environment map[string]string) []string {
+	keys := make([]string, 0, len(environment))
+	for key := range environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func newL2Source(sourceProps *input.SourceConfigurationProps, source *imageSource, connectionArn string, environment map[string]string) apprunner.Source {
+	environment = mergeEnvironment(sourceProps, environment)
+	l2Environment := map[string]*string{}
+	for _, key := range sortedKeys(environment) {
+		l2Environment[key] = jsii.String(environment[key])
+	}
+
+	if sourceProps.SourceType == input.SourceTypeImage {
+		imageConfiguration := &apprunner.ImageConfiguration{
+			Port:        jsii.Number(float64(sourceProps.Port)),
+			Environment: &l2Environment,
+		}
+		if source.asset != nil {
+			return apprunner.Source_FromAsset(&apprunner.AssetProps{
+				Asset:              source.asset,
+				ImageConfiguration: imageConfiguration,
+			})
+		}
+		return apprunner.Source_FromEcr(&apprunner.EcrProps{
+			Repository:         source.repository,
+			TagOrDigest:        source.tag,
+			ImageConfiguration: imageConfiguration,
+		})
+	}
+
+	if sourceProps.ConfigurationSource == input.ConfigurationSourceRepository {
+		return apprunner.Source_FromGitHub(&apprunner.GithubRepositoryProps{
+			RepositoryUrl:       jsii.String(sourceProps.RepositoryUrl),
+			Branch:              jsii.String(sourceProps.BranchName),
+			ConfigurationSource: apprunner.ConfigurationSourceType_REPOSITORY,
+			Connection:          apprunner.GitHubConnection_FromConnectionArn(jsii.String(connectionArn)),
+		})
+	}
+
+	return apprunner.Source_FromGitHub(&apprunner.GithubRepositoryProps{
+		RepositoryUrl:       jsii.String(sourceProps.RepositoryUrl),
+		Branch:              jsii.String(sourceProps.BranchName),
+		ConfigurationSource: apprunner.ConfigurationSourceType_API,
+		CodeConfigurationValues: &apprunner.CodeConfigurationValues{
+			Runtime:      apprunner.Runtime_Of(jsii.String(sourceProps.Runtime)),
+			Port:         jsii.String(strconv.Itoa(sourceProps.Port)),
+			StartCommand: jsii.String(sourceProps.StartCommand),
+			BuildCommand: jsii.String(sourceProps.BuildCommand),
+			Environment:  &l2Environment,
+		},
+		Connection: apprunner.GitHubConnection_FromConnectionArn(jsii.String(connectionArn)),
+	})
+}
+
+func newL1SourceConfiguration(sourceProps *input.SourceConfigurationProps, source *imageSource, connectionArn string, environment map[string]string) *awsapprunner.CfnService_SourceConfigurationProperty {
+	environment = mergeEnvironment(sourceProps, environment)
+	l1Environment := []interface{}{}
+	for _, key := range sortedKeys(environment) {
+		l1Environment = append(l1Environment, &awsapprunner.CfnService_KeyValuePairProperty{
+			Name:  jsii.String(key),
+			Value: jsii.String(environment[key]),
+		})
+	}
+
+	if sourceProps.SourceType == input.SourceTypeImage {
+		return &awsapprunner.CfnService_SourceConfigurationProperty{
+			AutoDeploymentsEnabled: jsii.Bool(true),
+			AuthenticationConfiguration: &awsapprunner.CfnService_AuthenticationConfigurationProperty{
+				AccessRoleArn: source.accessRole.RoleArn(),
+			},
+			ImageRepository: &awsapprunner.CfnService_ImageRepositoryProperty{
+				ImageIdentifier:     source.imageUri,
+				ImageRepositoryType: jsii.String("ECR"),
+				ImageConfiguration: &awsapprunner.CfnService_ImageConfigurationProperty{
+					Port:                        jsii.String(strconv.Itoa(sourceProps.Port)),
+					RuntimeEnvironmentVariables: l1Environment,
+				},
+			},
+		}
+	}
+
+	codeConfiguration := &awsapprunner.CfnService_CodeConfigurationProperty{
+		ConfigurationSource: jsii.String(sourceProps.ConfigurationSource),
+	}
+	if sourceProps.ConfigurationSource == input.ConfigurationSourceApi {
+		codeConfiguration.CodeConfigurationValues = &awsapprunner.CfnService_CodeConfigurationValuesProperty{
+			Runtime:                     jsii.String(sourceProps.Runtime),
+			Port:                        jsii.String(strconv.Itoa(sourceProps.Port)),
+			StartCommand:                jsii.String(sourceProps.StartCommand),
+			BuildCommand:                jsii.String(sourceProps.BuildCommand),
+			RuntimeEnvironmentVariables: l1Environment,
+		}
+	}
+
+	return &awsapprunner.CfnService_SourceConfigurationProperty{
+		AutoDeploymentsEnabled: jsii.Bool(true),
+		AuthenticationConfiguration: &awsapprunner.CfnService_AuthenticationConfigurationProperty{
+			ConnectionArn: jsii.String(connectionArn),
+		},
+		CodeRepository: &awsapprunner.CfnService_CodeRepositoryProperty{
+			RepositoryUrl: jsii.String(sourceProps.RepositoryUrl),
+			SourceCodeVersion: &awsapprunner.CfnService_SourceCodeVersionProperty{
+				Type:  jsii.String("BRANCH"),
+				Value: jsii.String(sourceProps.BranchName),
+			},
+			CodeConfiguration: codeConfiguration,
+		},
+	}
+}
+
+// withTracing adds TRACE_ENABLED to the environment variables of the service, with which the app exports the traces.
+func withTracing(environment map[string]string, traceEnabled bool) map[string]string {
+	if !traceEnabled {
+		return environment
+	}
+	merged := map[string]string{"TRACE_ENABLED": "true"}
+	for key, value := range environment {
+		merged[key] = value
+	}
+	return merged
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"go-cdk-go-managed-apprunner/cdk/input"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-cdk-go/awscdk/v2"
+	"github.com/aws/jsii-runtime-go"
+)
+
+// stackTags returns the tags of the stack: Tags of the props with Stage, unless Tags has its own Stage.
+func stackTags(inputProps *input.AppRunnerStackInputProps) map[string]string {
+	tags := map[string]string{}
+	if inputProps.Stage != "" {
+		tags["Stage"] = inputProps.Stage
+	}
+	for key, value := range inputProps.Tags {
+		if strings.HasPrefix(strings.ToLower(key), "aws:") {
+			panic(fmt.Errorf("Tag keys must not start with aws: %s", key))
+		}
+		if len(key) == 0 || len(key) > 128 || len(value) > 256 {
+			panic(fmt.Errorf("Tag keys must be 1 to 128 characters and values up to 256 characters: %s=%s", key, value))
+		}
+		tags[key] = value
+	}
+	return tags
+}
+
+// untaggedResourceTypes are the App Runner resources whose Tags CloudFormation cannot update:
+// a tag change replaces them, and a replaced service gets a new URL.
+var untaggedResourceTypes = []string{
+	"AWS::AppRunner::Service",
+	"AWS::AppRunner::VpcConnector",
+	"AWS::AppRunner::ObservabilityConfiguration",
+}
+
+// applyTags tags every taggable construct of the stack but untaggedResourceTypes.
+// The custom resources are not taggable, so the AutoScalingConfigurations get the tags through their Tags property.
+func applyTags(stack awscdk.Stack, tags map[string]string) {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		awscdk.Tags_Of(stack).Add(jsii.String(key), jsii.String(tags[key]), &awscdk.TagProps{
+			ExcludeResourceTypes: jsii.Strings(untaggedResourceTypes...),
+		})
+	}
+}
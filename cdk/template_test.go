@@ -0,0 +1,172 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// assetHashPattern matches the asset hashes (SHA-256) in S3 keys, image tags and asset paths.
+var assetHashPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+
+const assetHashPlaceholder = "ASSET_HASH"
+
+// normalizeTemplate returns a copy of the synthesized template for snapshot tests,
+// with the asset hashes scrubbed wherever they appear, the aws:asset:* metadata removed,
+// and the BootstrapVersion parameter and rule removed.
+func normalizeTemplate(templateJson *map[string]interface{}) map[string]interface{} {
+	if templateJson == nil {
+		return map[string]interface{}{}
+	}
+
+	normalized, ok := normalizeValue(*templateJson).(map[string]interface{})
+	if !ok {
+		return map[string]interface{}{}
+	}
+
+	deleteNested(normalized, "Parameters", "BootstrapVersion")
+	deleteNested(normalized, "Rules", "CheckBootstrapVersion")
+
+	return normalized
+}
+
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		normalized := map[string]interface{}{}
+		for key, element := range v {
+			if key == "Metadata" {
+				if metadata := normalizeMetadata(element); metadata != nil {
+					normalized[key] = metadata
+				}
+				continue
+			}
+			normalized[key] = normalizeValue(element)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, 0, len(v))
+		for _, element := range v {
+			normalized = append(normalized, normalizeValue(element))
+		}
+		return normalized
+	case string:
+		return assetHashPattern.ReplaceAllString(v, assetHashPlaceholder)
+	default:
+		return v
+	}
+}
+
+// normalizeMetadata removes the aws:asset:* keys, and returns nil if nothing is left.
+func normalizeMetadata(value interface{}) interface{} {
+	metadata, ok := value.(map[string]interface{})
+	if !ok {
+		return normalizeValue(value)
+	}
+
+	normalized := map[string]interface{}{}
+	for key, element := range metadata {
+		if strings.HasPrefix(key, "aws:asset:") {
+			continue
+		}
+		normalized[key] = normalizeValue(element)
+	}
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+// deleteNested deletes template[section][key], and the section itself if it gets empty.
+func deleteNested(template map[string]interface{}, section string, key string) {
+	values, ok := template[section].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	delete(values, key)
+	if len(values) == 0 {
+		delete(template, section)
+	}
+}
+
+func TestNormalizeTemplate(t *testing.T) {
+	hash := strings.Repeat("0123456789abcdef", 4)
+
+	templateJson := map[string]interface{}{
+		"Resources": map[string]interface{}{
+			"Function": map[string]interface{}{
+				"Type": "AWS::Lambda::Function",
+				"Properties": map[string]interface{}{
+					"Code": map[string]interface{}{
+						"S3Bucket": "cdk-hnb659fds-assets-123456789012-ap-northeast-1",
+						"S3Key":    hash + ".zip",
+					},
+				},
+				"Metadata": map[string]interface{}{
+					"aws:asset:path":       "asset." + hash,
+					"aws:asset:is-bundled": true,
+					"aws:asset:property":   "Code",
+					"aws:cdk:path":         "Stack/Function/Resource",
+					"cfn_nag":              "suppressed",
+				},
+			},
+			"Service": map[string]interface{}{
+				"Type": "AWS::AppRunner::Service",
+				"Properties": map[string]interface{}{
+					"ImageIdentifier": map[string]interface{}{
+						"Fn::Join": []interface{}{"", []interface{}{"123456789012.dkr.ecr.", map[string]interface{}{"Ref": "AWS::Region"}, ":" + hash}},
+					},
+				},
+				"Metadata": map[string]interface{}{
+					"aws:asset:path": "asset." + hash,
+				},
+			},
+		},
+		"Parameters": map[string]interface{}{
+			"BootstrapVersion": map[string]interface{}{"Type": "AWS::SSM::Parameter::Value<String>"},
+		},
+		"Rules": map[string]interface{}{
+			"CheckBootstrapVersion": map[string]interface{}{"Assertions": []interface{}{}},
+			"OtherRule":             map[string]interface{}{"Assertions": []interface{}{}},
+		},
+	}
+
+	want := map[string]interface{}{
+		"Resources": map[string]interface{}{
+			"Function": map[string]interface{}{
+				"Type": "AWS::Lambda::Function",
+				"Properties": map[string]interface{}{
+					"Code": map[string]interface{}{
+						"S3Bucket": "cdk-hnb659fds-assets-123456789012-ap-northeast-1",
+						"S3Key":    assetHashPlaceholder + ".zip",
+					},
+				},
+				"Metadata": map[string]interface{}{
+					"aws:cdk:path": "Stack/Function/Resource",
+					"cfn_nag":      "suppressed",
+				},
+			},
+			"Service": map[string]interface{}{
+				"Type": "AWS::AppRunner::Service",
+				"Properties": map[string]interface{}{
+					"ImageIdentifier": map[string]interface{}{
+						"Fn::Join": []interface{}{"", []interface{}{"123456789012.dkr.ecr.", map[string]interface{}{"Ref": "AWS::Region"}, ":" + assetHashPlaceholder}},
+					},
+				},
+			},
+		},
+		"Rules": map[string]interface{}{
+			"OtherRule": map[string]interface{}{"Assertions": []interface{}{}},
+		},
+	}
+
+	if got := normalizeTemplate(&templateJson); !reflect.DeepEqual(got, want) {
+		t.Errorf("normalizeTemplate() =\n%v\nwant\n%v", got, want)
+	}
+
+	if got := normalizeTemplate(nil); len(got) != 0 {
+		t.Errorf("normalizeTemplate(nil) = %v, want empty", got)
+	}
+}
@@ -0,0 +1,54 @@
+package apprunnerops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// ListAllRevisions makes ListAutoScalingConfigurations list all the revisions of each name.
+// The default of LatestOnly is true, which lists only the latest revision, but the SDK omits LatestOnly
+// unless it is true, so LatestOnly=false is set to the serialized request body instead.
+func ListAllRevisions(options *apprunner.Options) {
+	options.APIOptions = append(options.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Serialize.Add(listAllRevisions, middleware.After)
+	})
+}
+
+// listAllRevisions is added after the serializer of the operation, which has set the JSON body.
+var listAllRevisions = middleware.SerializeMiddlewareFunc("ListAllRevisions", func(
+	ctx context.Context, in middleware.SerializeInput, next middleware.SerializeHandler,
+) (middleware.SerializeOutput, middleware.Metadata, error) {
+	request, ok := in.Request.(*smithyhttp.Request)
+	if !ok {
+		return middleware.SerializeOutput{}, middleware.Metadata{}, fmt.Errorf("unexpected request type %T", in.Request)
+	}
+
+	fields := map[string]json.RawMessage{}
+	if stream := request.GetStream(); stream != nil {
+		body, err := io.ReadAll(stream)
+		if err != nil {
+			return middleware.SerializeOutput{}, middleware.Metadata{}, err
+		}
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return middleware.SerializeOutput{}, middleware.Metadata{}, err
+		}
+	}
+	fields["LatestOnly"] = json.RawMessage("false")
+
+	body, err := json.Marshal(fields)
+	if err != nil {
+		return middleware.SerializeOutput{}, middleware.Metadata{}, err
+	}
+	if in.Request, err = request.SetStream(bytes.NewReader(body)); err != nil {
+		return middleware.SerializeOutput{}, middleware.Metadata{}, err
+	}
+
+	return next.HandleSerialize(ctx, in)
+})
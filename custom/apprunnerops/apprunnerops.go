@@ -0,0 +1,496 @@
+// Package apprunnerops provides the App Runner operations shared by the Lambda functions of this repository.
+package apprunnerops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-cdk-go-managed-apprunner/custom/logging"
+	"go-cdk-go-managed-apprunner/custom/metrics"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"golang.org/x/sync/errgroup"
+)
+
+// ListAutoScalingConfiguration returns all the ACTIVE revisions of the name in all the pages, not only the latest revision,
+// or the revisions of all the names of the account if the name is empty.
+func ListAutoScalingConfiguration(ctx context.Context, client *apprunner.Client, autoScalingConfigurationName string) ([]types.AutoScalingConfigurationSummary, error) {
+	input := &apprunner.ListAutoScalingConfigurationsInput{}
+	if autoScalingConfigurationName != "" {
+		input.AutoScalingConfigurationName = aws.String(autoScalingConfigurationName)
+	}
+	paginator := apprunner.NewListAutoScalingConfigurationsPaginator(client, input)
+
+	summaries := []types.AutoScalingConfigurationSummary{}
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx, ListAllRevisions)
+		if err != nil {
+			return nil, err
+		}
+		summaries = append(summaries, output.AutoScalingConfigurationSummaryList...)
+	}
+
+	return summaries, nil
+}
+
+func CreateAutoScalingConfiguration(
+	ctx context.Context,
+	client *apprunner.Client,
+	autoScalingConfigurationName string,
+	maxConcurrency int,
+	maxSize int,
+	minSize int,
+	tags map[string]string,
+) (string, error) {
+	output, err := client.CreateAutoScalingConfiguration(ctx, &apprunner.CreateAutoScalingConfigurationInput{
+		AutoScalingConfigurationName: aws.String(autoScalingConfigurationName),
+		MaxConcurrency:               aws.Int32(int32(maxConcurrency)),
+		MaxSize:                      aws.Int32(int32(maxSize)),
+		MinSize:                      aws.Int32(int32(minSize)),
+		Tags:                         toTags(tags),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return *output.AutoScalingConfiguration.AutoScalingConfigurationArn, nil
+}
+
+func ListTags(ctx context.Context, client *apprunner.Client, resourceArn string) (map[string]string, error) {
+	output, err := client.ListTagsForResource(ctx, &apprunner.ListTagsForResourceInput{
+		ResourceArn: aws.String(resourceArn),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := map[string]string{}
+	for _, tag := range output.Tags {
+		tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+	return tags, nil
+}
+
+// SyncTags makes the resource have the tags and none of the removed tag keys,
+// calling TagResource and UntagResource only for the differences from the current tags.
+// The other tags of the resource, e.g. added outside of the stack, are kept.
+func SyncTags(ctx context.Context, client *apprunner.Client, resourceArn string, tags map[string]string, removedTagKeys []string) error {
+	current, err := ListTags(ctx, client, resourceArn)
+	if err != nil {
+		return err
+	}
+
+	changed := map[string]string{}
+	for key, value := range tags {
+		if currentValue, ok := current[key]; !ok || currentValue != value {
+			changed[key] = value
+		}
+	}
+	if len(changed) > 0 {
+		if _, err := client.TagResource(ctx, &apprunner.TagResourceInput{
+			ResourceArn: aws.String(resourceArn),
+			Tags:        toTags(changed),
+		}); err != nil {
+			return err
+		}
+	}
+
+	untagKeys := []string{}
+	for _, key := range removedTagKeys {
+		if _, ok := tags[key]; ok {
+			continue
+		}
+		if _, ok := current[key]; ok {
+			untagKeys = append(untagKeys, key)
+		}
+	}
+	if len(untagKeys) > 0 {
+		sort.Strings(untagKeys)
+		if _, err := client.UntagResource(ctx, &apprunner.UntagResourceInput{
+			ResourceArn: aws.String(resourceArn),
+			TagKeys:     untagKeys,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// toTags returns the tags sorted by the keys, or nil for no tags.
+func toTags(tags map[string]string) []types.Tag {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := []types.Tag{}
+	for _, key := range keys {
+		result = append(result, types.Tag{
+			Key:   aws.String(key),
+			Value: aws.String(tags[key]),
+		})
+	}
+	return result
+}
+
+func DeleteAutoScalingConfiguration(ctx context.Context, client *apprunner.Client, autoScalingConfigurationArn string) error {
+	_, err := client.DeleteAutoScalingConfiguration(ctx, &apprunner.DeleteAutoScalingConfigurationInput{
+		AutoScalingConfigurationArn: aws.String(autoScalingConfigurationArn),
+	})
+
+	return err
+}
+
+func DescribeAutoScalingConfiguration(ctx context.Context, client *apprunner.Client, autoScalingConfigurationArn string) (*types.AutoScalingConfiguration, error) {
+	output, err := client.DescribeAutoScalingConfiguration(ctx, &apprunner.DescribeAutoScalingConfigurationInput{
+		AutoScalingConfigurationArn: aws.String(autoScalingConfigurationArn),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return output.AutoScalingConfiguration, nil
+}
+
+// FindAutoScalingConfigurations returns the ARNs of the ACTIVE revisions of the name with the values from the newest revision.
+// All the pages of the revisions are searched.
+func FindAutoScalingConfigurations(ctx context.Context, client *apprunner.Client, autoScalingConfigurationName string, maxConcurrency int, maxSize int, minSize int) ([]string, error) {
+	return findAutoScalingConfigurations(ctx, client, autoScalingConfigurationName, maxConcurrency, maxSize, minSize, false)
+}
+
+// FindAutoScalingConfiguration returns the ARN of the newest ACTIVE revision of the name with the values, or "" if none.
+// The revisions are described from the newest until the first match.
+func FindAutoScalingConfiguration(ctx context.Context, client *apprunner.Client, autoScalingConfigurationName string, maxConcurrency int, maxSize int, minSize int) (string, error) {
+	arns, err := findAutoScalingConfigurations(ctx, client, autoScalingConfigurationName, maxConcurrency, maxSize, minSize, true)
+	if err != nil || len(arns) == 0 {
+		return "", err
+	}
+	return arns[0], nil
+}
+
+func findAutoScalingConfigurations(ctx context.Context, client *apprunner.Client, autoScalingConfigurationName string, maxConcurrency int, maxSize int, minSize int, firstOnly bool) ([]string, error) {
+	revisions, err := ListAutoScalingConfigurationRevisions(ctx, client, autoScalingConfigurationName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The summaries have no values, so only the revisions of the name are described.
+	arns := []string{}
+	for _, revision := range revisions {
+		configuration, err := DescribeAutoScalingConfiguration(ctx, client, *revision.AutoScalingConfigurationArn)
+		if err != nil {
+			return nil, err
+		}
+		if configuration.Status != types.AutoScalingConfigurationStatusActive {
+			continue
+		}
+		if aws.ToInt32(configuration.MaxConcurrency) == int32(maxConcurrency) &&
+			aws.ToInt32(configuration.MaxSize) == int32(maxSize) &&
+			aws.ToInt32(configuration.MinSize) == int32(minSize) {
+			arns = append(arns, *configuration.AutoScalingConfigurationArn)
+			if firstOnly {
+				break
+			}
+		}
+	}
+
+	return arns, nil
+}
+
+// ListAutoScalingConfigurationRevisions returns the summaries of the ACTIVE revisions of the name from the newest revision.
+// All the pages of all the revisions are listed, not only the latest revision.
+func ListAutoScalingConfigurationRevisions(ctx context.Context, client *apprunner.Client, autoScalingConfigurationName string) ([]types.AutoScalingConfigurationSummary, error) {
+	summaries, err := ListAutoScalingConfiguration(ctx, client, autoScalingConfigurationName)
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := []types.AutoScalingConfigurationSummary{}
+	for _, summary := range summaries {
+		if aws.ToString(summary.AutoScalingConfigurationName) == autoScalingConfigurationName &&
+			summary.Status == types.AutoScalingConfigurationStatusActive {
+			revisions = append(revisions, summary)
+		}
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].AutoScalingConfigurationRevision > revisions[j].AutoScalingConfigurationRevision
+	})
+	return revisions, nil
+}
+
+// GetServiceAutoScalingConfigurationArns returns the AutoScalingConfiguration ARNs of the services of the stack by the service ARNs.
+// The services already deleted, e.g. on the stack deletion, are not included.
+func GetServiceAutoScalingConfigurationArns(
+	ctx context.Context,
+	apprunnerClient *apprunner.Client,
+	cfnClient *cloudformation.Client,
+	stackName string,
+	exportNames []string,
+) (map[string]string, error) {
+	serviceArns, err := GetServiceArns(ctx, cfnClient, stackName, exportNames)
+	if err != nil {
+		return nil, err
+	}
+
+	autoScalingConfigurationArns := map[string]string{}
+	for _, serviceArn := range serviceArns {
+		output, err := apprunnerClient.DescribeService(ctx, &apprunner.DescribeServiceInput{
+			ServiceArn: aws.String(serviceArn),
+		})
+		var notFound *types.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if output.Service.Status == types.ServiceStatusDeleted || output.Service.AutoScalingConfigurationSummary == nil {
+			continue
+		}
+		autoScalingConfigurationArns[serviceArn] = aws.ToString(output.Service.AutoScalingConfigurationSummary.AutoScalingConfigurationArn)
+	}
+
+	return autoScalingConfigurationArns, nil
+}
+
+// GetServiceArns returns the service ARNs of the stack outputs with the export names.
+// If no export names are given, all the service ARNs of the stack ("<StackName>AppRunnerService*ServiceArn") are returned.
+func GetServiceArns(ctx context.Context, client *cloudformation.Client, stackName string, exportNames []string) ([]string, error) {
+	stacks, err := client.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	arns := []string{}
+	for _, output := range stacks.Stacks[0].Outputs {
+		exportName := aws.ToString(output.ExportName)
+		if isServiceArnExportName(stackName, exportNames, exportName) {
+			arns = append(arns, *output.OutputValue)
+		}
+	}
+
+	return arns, nil
+}
+
+func isServiceArnExportName(stackName string, exportNames []string, exportName string) bool {
+	if len(exportNames) == 0 {
+		return strings.HasPrefix(exportName, stackName+"AppRunnerService") && strings.HasSuffix(exportName, "ServiceArn")
+	}
+
+	for _, name := range exportNames {
+		if exportName == name {
+			return true
+		}
+	}
+	return false
+}
+
+// OperationPollInterval is the interval of WaitOperation to poll the operation status,
+// which is shortened by custom/localrun for the fake API.
+var OperationPollInterval = 10 * time.Second
+
+// WaitOperation waits for the operation to succeed, logging the status transitions with the logger of the context.
+// It polls every OperationPollInterval, also while the operation is not yet listed, until the context is done.
+func WaitOperation(ctx context.Context, apprunnerClient *apprunner.Client, operationId string, serviceArn string) error {
+	if operationId == "" {
+		return fmt.Errorf("OperationId is empty")
+	}
+
+	logger := logging.FromContext(ctx).With("ServiceArn", serviceArn, "OperationId", operationId)
+	start := time.Now()
+	var lastStatus types.OperationStatus
+	for {
+		output, err := apprunnerClient.ListOperations(ctx, &apprunner.ListOperationsInput{
+			ServiceArn: aws.String(serviceArn),
+		})
+		if err != nil {
+			return err
+		}
+		if len(output.OperationSummaryList) == 0 {
+			return fmt.Errorf("OperationSummaryList is empty")
+		}
+
+		for _, operationSummary := range output.OperationSummaryList {
+			if aws.ToString(operationSummary.Id) != operationId {
+				continue
+			}
+			if operationSummary.Status != lastStatus {
+				logger.Info("operation status changed",
+					"From", string(lastStatus),
+					"To", string(operationSummary.Status),
+					"ElapsedMs", time.Since(start).Milliseconds(),
+				)
+				lastStatus = operationSummary.Status
+			}
+			if operationSummary.Status == types.OperationStatusSucceeded {
+				return nil
+			} else if operationSummary.Status != types.OperationStatusInProgress && operationSummary.Status != types.OperationStatusPending {
+				return fmt.Errorf("OperationError status:" + string(operationSummary.Status))
+			}
+		}
+
+		timer := time.NewTimer(OperationPollInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func UpdateServiceForAutoScalingConfiguration(
+	ctx context.Context,
+	apprunnerClient *apprunner.Client,
+	cfnClient *cloudformation.Client,
+	stackName string,
+	exportNames []string,
+	autoScalingConfigurationArn string,
+) error {
+	serviceArns, err := GetServiceArns(ctx, cfnClient, stackName, exportNames)
+	if err != nil {
+		return err
+	}
+	if len(serviceArns) == 0 {
+		return fmt.Errorf("Service Arns not found")
+	}
+
+	return UpdateServices(ctx, apprunnerClient, serviceArns, autoScalingConfigurationArn)
+}
+
+// UpdateServices moves the services to the AutoScalingConfiguration in parallel and waits for the operations,
+// recording ServicesUpdated and OperationWaitTime to the metrics recorder of the context.
+func UpdateServices(ctx context.Context, apprunnerClient *apprunner.Client, serviceArns []string, autoScalingConfigurationArn string) error {
+	eg, ctx := errgroup.WithContext(ctx)
+	for _, serviceArn := range serviceArns {
+		serviceArn := serviceArn
+		eg.Go(func() error {
+			start := time.Now()
+			output, err := apprunnerClient.UpdateService(ctx, &apprunner.UpdateServiceInput{
+				ServiceArn:                  aws.String(serviceArn),
+				AutoScalingConfigurationArn: aws.String(autoScalingConfigurationArn),
+			})
+			if err != nil {
+				return err
+			}
+			logging.FromContext(ctx).Info("operation started",
+				"ServiceArn", serviceArn,
+				"OperationId", aws.ToString(output.OperationId),
+				"AutoScalingConfigurationArn", autoScalingConfigurationArn,
+			)
+
+			if err = WaitOperation(ctx, apprunnerClient, *output.OperationId, serviceArn); err != nil {
+				return err
+			}
+
+			recorder := metrics.FromContext(ctx)
+			recorder.Add(metrics.ServicesUpdated, 1)
+			recorder.PutOperationWaitTime(serviceName(serviceArn), time.Since(start))
+			return nil
+		})
+	}
+
+	return eg.Wait()
+}
+
+// serviceName returns the service name of the service ARN (arn:aws:apprunner:region:account:service/name/id).
+func serviceName(serviceArn string) string {
+	parts := strings.Split(serviceArn, "/")
+	if len(parts) < 3 {
+		return serviceArn
+	}
+	return parts[1]
+}
+
+// GetDefaultConfigurationArn returns the ARN of the newest revision of DefaultConfiguration,
+// which App Runner creates in every account. It is not always the account default,
+// which GetDefaultAutoScalingConfigurationArn resolves.
+func GetDefaultConfigurationArn(ctx context.Context, client *apprunner.Client) (string, error) {
+	configurations, err := ListAutoScalingConfiguration(ctx, client, "DefaultConfiguration")
+	if err != nil {
+		return "", err
+	}
+	if len(configurations) == 0 {
+		return "", fmt.Errorf("DefaultConfiguration not found")
+	}
+
+	arn := configurations[0].AutoScalingConfigurationArn
+	revision := configurations[0].AutoScalingConfigurationRevision
+	for _, configuration := range configurations[1:] {
+		if configuration.AutoScalingConfigurationRevision > revision {
+			arn = configuration.AutoScalingConfigurationArn
+			revision = configuration.AutoScalingConfigurationRevision
+		}
+	}
+	return *arn, nil
+}
+
+// GetDefaultAutoScalingConfigurationArn returns the ARN of the account default revision (IsDefault),
+// which may be an older revision than the latest of its name.
+// Without IsDefault in the response, e.g. in the regions before the account default, it is the newest revision of DefaultConfiguration.
+func GetDefaultAutoScalingConfigurationArn(ctx context.Context, client *apprunner.Client) (string, error) {
+	summaries, err := ListAutoScalingConfiguration(ctx, client, "")
+	if err != nil {
+		return "", err
+	}
+
+	var defaultConfiguration *types.AutoScalingConfigurationSummary
+	for i, summary := range summaries {
+		if aws.ToBool(summary.IsDefault) {
+			return *summary.AutoScalingConfigurationArn, nil
+		}
+		if aws.ToString(summary.AutoScalingConfigurationName) == "DefaultConfiguration" &&
+			(defaultConfiguration == nil || summary.AutoScalingConfigurationRevision > defaultConfiguration.AutoScalingConfigurationRevision) {
+			defaultConfiguration = &summaries[i]
+		}
+	}
+	if defaultConfiguration == nil {
+		return "", fmt.Errorf("no default AutoScalingConfiguration of the account: neither IsDefault nor DefaultConfiguration found")
+	}
+
+	return *defaultConfiguration.AutoScalingConfigurationArn, nil
+}
+
+// UpdateDefaultAutoScalingConfiguration makes the revision the account default,
+// which the services created without an AutoScalingConfiguration use.
+func UpdateDefaultAutoScalingConfiguration(ctx context.Context, client *apprunner.Client, autoScalingConfigurationArn string) error {
+	_, err := client.UpdateDefaultAutoScalingConfiguration(ctx, &apprunner.UpdateDefaultAutoScalingConfigurationInput{
+		AutoScalingConfigurationArn: aws.String(autoScalingConfigurationArn),
+	})
+
+	return err
+}
+
+// ListServicesForAutoScalingConfiguration returns the ARNs of the services using the revision. All the pages are listed.
+func ListServicesForAutoScalingConfiguration(ctx context.Context, client *apprunner.Client, autoScalingConfigurationArn string) ([]string, error) {
+	paginator := apprunner.NewListServicesForAutoScalingConfigurationPaginator(client, &apprunner.ListServicesForAutoScalingConfigurationInput{
+		AutoScalingConfigurationArn: aws.String(autoScalingConfigurationArn),
+	})
+
+	serviceArns := []string{}
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		serviceArns = append(serviceArns, output.ServiceArnList...)
+	}
+
+	return serviceArns, nil
+}
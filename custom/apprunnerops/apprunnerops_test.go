@@ -0,0 +1,188 @@
+package apprunnerops
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+)
+
+func TestGetDefaultAutoScalingConfigurationArn(t *testing.T) {
+	apprunnerClient, _, server, _ := newTestClients(t)
+	ctx := context.Background()
+
+	defaultArn, err := GetDefaultAutoScalingConfigurationArn(ctx, apprunnerClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := server.DefaultAutoScalingConfigurationArn(); defaultArn != want {
+		t.Errorf("default = %s, want DefaultConfiguration %s", defaultArn, want)
+	}
+
+	// The account default stays on its revision when a newer revision of the name is created.
+	accountDefaultArn := server.AddAutoScalingConfiguration("Platform", 100, 10, 1)
+	server.SetAccountDefault(accountDefaultArn)
+	server.AddAutoScalingConfiguration("Platform", 100, 20, 1)
+
+	defaultArn, err = GetDefaultAutoScalingConfigurationArn(ctx, apprunnerClient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultArn != accountDefaultArn {
+		t.Errorf("default = %s, want the older revision %s", defaultArn, accountDefaultArn)
+	}
+}
+
+func TestWaitOperation(t *testing.T) {
+	t.Run("not listed", func(t *testing.T) {
+		apprunnerClient, _, server, serviceArns := newTestClients(t)
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		if _, err := apprunnerClient.UpdateService(ctx, &apprunner.UpdateServiceInput{
+			ServiceArn:                  aws.String(serviceArns[0]),
+			AutoScalingConfigurationArn: aws.String(server.DefaultAutoScalingConfigurationArn()),
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		// The missing operation is polled at the interval, not in a busy loop.
+		err := WaitOperation(ctx, apprunnerClient, "unknown", serviceArns[0])
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if got := countCalls(server, "AppRunner.ListOperations"); got < 2 || got > 20 {
+			t.Errorf("ListOperations called %d times in 100ms with the interval of 10ms", got)
+		}
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		apprunnerClient, _, server, serviceArns := newTestClients(t)
+		server.OperationDuration = time.Hour
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		output, err := apprunnerClient.UpdateService(ctx, &apprunner.UpdateServiceInput{
+			ServiceArn:                  aws.String(serviceArns[0]),
+			AutoScalingConfigurationArn: aws.String(server.DefaultAutoScalingConfigurationArn()),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		start := time.Now()
+		err = WaitOperation(ctx, apprunnerClient, aws.ToString(output.OperationId), serviceArns[0])
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("returned after %v, not on the deadline", elapsed)
+		}
+	})
+}
+
+// recordingClient records the JSON bodies of the App Runner requests of the target, e.g. "AppRunner.ListAutoScalingConfigurations".
+type recordingClient struct {
+	target string
+	mu     sync.Mutex
+	bodies []map[string]interface{}
+}
+
+func (c *recordingClient) Do(request *http.Request) (*http.Response, error) {
+	if request.Header.Get("X-Amz-Target") == c.target {
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			return nil, err
+		}
+		request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.bodies = append(c.bodies, fields)
+		c.mu.Unlock()
+	}
+	return http.DefaultClient.Do(request)
+}
+
+func TestListAutoScalingConfiguration(t *testing.T) {
+	_, _, server, _ := newTestClients(t)
+	server.PageSize = 1
+	for _, maxSize := range []int32{10, 20, 30} {
+		server.AddAutoScalingConfiguration("Platform", 100, maxSize, 1)
+	}
+
+	// The SDK omits LatestOnly=false, and the API defaults to the latest revision only,
+	// so the request body must have LatestOnly=false set by ListAllRevisions on every page.
+	recorder := &recordingClient{target: "AppRunner.ListAutoScalingConfigurations"}
+	cfg := server.Config()
+	cfg.HTTPClient = recorder
+	apprunnerClient := apprunner.NewFromConfig(cfg)
+
+	summaries, err := ListAutoScalingConfiguration(context.Background(), apprunnerClient, "Platform")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 3 {
+		t.Errorf("%d revisions listed, want 3", len(summaries))
+	}
+
+	if len(recorder.bodies) < 3 {
+		t.Fatalf("%d requests, want a request per page", len(recorder.bodies))
+	}
+	for i, body := range recorder.bodies {
+		if latestOnly, ok := body["LatestOnly"]; !ok || latestOnly != false {
+			t.Errorf("request %d: LatestOnly = %v, want false", i, latestOnly)
+		}
+		if name := body["AutoScalingConfigurationName"]; name != "Platform" {
+			t.Errorf("request %d: AutoScalingConfigurationName = %v, want Platform", i, name)
+		}
+		if _, ok := body["NextToken"]; ok != (i > 0) {
+			t.Errorf("request %d: NextToken = %v", i, body["NextToken"])
+		}
+	}
+}
+
+func TestFindAutoScalingConfiguration(t *testing.T) {
+	apprunnerClient, _, server, _ := newTestClients(t)
+	ctx := context.Background()
+
+	server.AddAutoScalingConfiguration("Other", 100, 10, 1)
+	server.AddAutoScalingConfiguration("Platform", 100, 10, 1)
+	server.AddAutoScalingConfiguration("Platform", 100, 20, 1)
+	newestArn := server.AddAutoScalingConfiguration("Platform", 100, 10, 1)
+
+	// Only the newest revision is described when it has the values.
+	arn, err := FindAutoScalingConfiguration(ctx, apprunnerClient, "Platform", 100, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arn != newestArn {
+		t.Errorf("ARN = %s, want the newest revision %s", arn, newestArn)
+	}
+	if got := countCalls(server, "AppRunner.DescribeAutoScalingConfiguration"); got != 1 {
+		t.Errorf("DescribeAutoScalingConfiguration called %d times, want 1", got)
+	}
+
+	// Without a match, only the revisions of the name are described.
+	arn, err = FindAutoScalingConfiguration(ctx, apprunnerClient, "Platform", 100, 30, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arn != "" {
+		t.Errorf("ARN = %s, want none", arn)
+	}
+	if got := countCalls(server, "AppRunner.DescribeAutoScalingConfiguration"); got != 1+3 {
+		t.Errorf("DescribeAutoScalingConfiguration called %d times, want 4", got)
+	}
+}
@@ -0,0 +1,222 @@
+package apprunnerops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-cdk-go-managed-apprunner/custom/logging"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/smithy-go"
+)
+
+// The revisions of the stacks are tagged with the stack name: stackNameTagKey by the custom resources (cdk),
+// and cloudFormationStackNameTagKey by CloudFormation for the native resources.
+const (
+	stackNameTagKey               = "AppRunnerStackName"
+	cloudFormationStackNameTagKey = "aws:cloudformation:stack-name"
+)
+
+// OrphanedAutoScalingConfiguration is a revision without services which no live stack owns.
+type OrphanedAutoScalingConfiguration struct {
+	AutoScalingConfigurationArn      string
+	AutoScalingConfigurationName     string
+	AutoScalingConfigurationRevision int32
+	Deleted                          bool
+	Error                            string `json:",omitempty"`
+}
+
+// FindOrphanedAutoScalingConfigurations returns the ACTIVE revisions of the account which are not
+//   - DefaultConfiguration or the account default,
+//   - matched by a pattern of the allowlist (path.Match against the name or the ARN),
+//   - used by any service (ListServicesForAutoScalingConfiguration),
+//   - owned by a live stack: the stack of the stack name tag.
+//
+// The revisions without the stack name tag, e.g. created outside of the stacks or before the tag was added, are kept
+// unless includeUntagged, with which their stacks are inferred from the names: "<StackName>" or "<StackName>-<Name>".
+// The revisions of a live stack without services, e.g. the scaling profiles or retained ones, are left to the stack.
+func FindOrphanedAutoScalingConfigurations(
+	ctx context.Context,
+	apprunnerClient *apprunner.Client,
+	cfnClient *cloudformation.Client,
+	allowlist []string,
+	includeUntagged bool,
+) ([]*OrphanedAutoScalingConfiguration, error) {
+	for _, pattern := range allowlist {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid allowlist pattern %q: %v", pattern, err)
+		}
+	}
+
+	summaries, err := ListAutoScalingConfiguration(ctx, apprunnerClient, "")
+	if err != nil {
+		return nil, err
+	}
+
+	logger := logging.FromContext(ctx)
+	liveStacks := map[string]bool{}
+	orphans := []*OrphanedAutoScalingConfiguration{}
+	for _, summary := range summaries {
+		if aws.ToBool(summary.IsDefault) || aws.ToString(summary.AutoScalingConfigurationName) == "DefaultConfiguration" {
+			continue
+		}
+		if allowed(allowlist, summary) {
+			logger.Info("revision allowlisted", "AutoScalingConfigurationArn", *summary.AutoScalingConfigurationArn)
+			continue
+		}
+
+		serviceArns, err := ListServicesForAutoScalingConfiguration(ctx, apprunnerClient, *summary.AutoScalingConfigurationArn)
+		if err != nil {
+			return nil, err
+		}
+		if len(serviceArns) > 0 {
+			continue
+		}
+
+		tags, err := ListTags(ctx, apprunnerClient, *summary.AutoScalingConfigurationArn)
+		if err != nil {
+			return nil, err
+		}
+		stackName := tags[stackNameTagKey]
+		if stackName == "" {
+			stackName = tags[cloudFormationStackNameTagKey]
+		}
+
+		switch {
+		case stackName != "":
+			live, err := isLiveStackCached(ctx, cfnClient, liveStacks, stackName)
+			if err != nil {
+				return nil, err
+			}
+			if !live {
+				stackName = ""
+			}
+		case includeUntagged:
+			stackName, err = owningStack(ctx, cfnClient, liveStacks, *summary.AutoScalingConfigurationName)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			logger.Info("revision without the stack name tag kept", "AutoScalingConfigurationArn", *summary.AutoScalingConfigurationArn)
+			continue
+		}
+		if stackName != "" {
+			logger.Info("revision owned by a live stack", "AutoScalingConfigurationArn", *summary.AutoScalingConfigurationArn, "StackName", stackName)
+			continue
+		}
+
+		orphans = append(orphans, &OrphanedAutoScalingConfiguration{
+			AutoScalingConfigurationArn:      *summary.AutoScalingConfigurationArn,
+			AutoScalingConfigurationName:     *summary.AutoScalingConfigurationName,
+			AutoScalingConfigurationRevision: summary.AutoScalingConfigurationRevision,
+		})
+	}
+
+	return orphans, nil
+}
+
+// DeleteOrphanedAutoScalingConfigurations deletes the revisions one by one.
+// A failure of one revision, e.g. attached to a service since it was found, does not stop the others.
+func DeleteOrphanedAutoScalingConfigurations(ctx context.Context, client *apprunner.Client, orphans []*OrphanedAutoScalingConfiguration) error {
+	failed := 0
+	for _, orphan := range orphans {
+		logger := logging.FromContext(ctx).With("AutoScalingConfigurationArn", orphan.AutoScalingConfigurationArn)
+		if err := DeleteAutoScalingConfiguration(ctx, client, orphan.AutoScalingConfigurationArn); err != nil {
+			orphan.Error = err.Error()
+			failed++
+			logger.Error("orphaned revision not deleted", "Error", err.Error())
+			continue
+		}
+		orphan.Deleted = true
+		logger.Info("orphaned revision deleted")
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d orphaned AutoScalingConfigurations not deleted", failed, len(orphans))
+	}
+	return nil
+}
+
+func allowed(allowlist []string, summary types.AutoScalingConfigurationSummary) bool {
+	for _, pattern := range allowlist {
+		if matched, _ := path.Match(pattern, *summary.AutoScalingConfigurationName); matched {
+			return true
+		}
+		if matched, _ := path.Match(pattern, *summary.AutoScalingConfigurationArn); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// owningStack returns the name of the live stack owning the AutoScalingConfiguration, or empty if there is none.
+// The stack names may contain "-", so every prefix of the name before a "-" is a candidate,
+// unless it cannot be a stack name (e.g. with "_", which the AutoScalingConfiguration names may contain).
+// liveStacks caches the results of DescribeStacks by the stack name.
+func owningStack(ctx context.Context, client *cloudformation.Client, liveStacks map[string]bool, autoScalingConfigurationName string) (string, error) {
+	candidates := []string{autoScalingConfigurationName}
+	for i := len(autoScalingConfigurationName) - 1; i > 0; i-- {
+		if autoScalingConfigurationName[i] == '-' {
+			candidates = append(candidates, autoScalingConfigurationName[:i])
+		}
+	}
+
+	for _, stackName := range candidates {
+		if !stackNamePattern.MatchString(stackName) {
+			continue
+		}
+		live, err := isLiveStackCached(ctx, client, liveStacks, stackName)
+		if err != nil {
+			return "", err
+		}
+		if live {
+			return stackName, nil
+		}
+	}
+	return "", nil
+}
+
+// isLiveStackCached is isLiveStack with the results cached in liveStacks by the stack name.
+func isLiveStackCached(ctx context.Context, client *cloudformation.Client, liveStacks map[string]bool, stackName string) (bool, error) {
+	if live, ok := liveStacks[stackName]; ok {
+		return live, nil
+	}
+	live, err := isLiveStack(ctx, client, stackName)
+	if err != nil {
+		return false, err
+	}
+	liveStacks[stackName] = live
+	return live, nil
+}
+
+var stackNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*$`)
+
+// isLiveStack reports whether the stack exists and has resources.
+// ROLLBACK_COMPLETE is the failed creation, whose resources have been deleted.
+func isLiveStack(ctx context.Context, client *cloudformation.Client, stackName string) (bool, error) {
+	output, err := client.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "ValidationError" && strings.Contains(apiErr.ErrorMessage(), "does not exist") {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, stack := range output.Stacks {
+		switch stack.StackStatus {
+		case "DELETE_COMPLETE", "ROLLBACK_COMPLETE":
+		default:
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,51 @@
+package apprunnerops
+
+import (
+	"context"
+	"testing"
+)
+
+func TestFindOrphanedAutoScalingConfigurationsUntagged(t *testing.T) {
+	apprunnerClient, cfnClient, server, _ := newTestClients(t)
+	ctx := context.Background()
+
+	// Created by another team, named like a revision of a deleted stack but without the stack name tag.
+	server.AddStack("Retired")
+	server.SetStackStatus("Retired", "DELETE_COMPLETE")
+	foreignArn := server.AddAutoScalingConfiguration("Retired-team", 50, 5, 1)
+
+	// A revision of the same deleted stack, which the tag tells to be orphaned.
+	taggedArn := server.AddAutoScalingConfiguration("Retired-nights", 50, 3, 1)
+	if err := SyncTags(ctx, apprunnerClient, taggedArn, map[string]string{stackNameTagKey: "Retired"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name            string
+		includeUntagged bool
+		want            []string
+	}{
+		{name: "kept by default", want: []string{taggedArn}},
+		{name: "include untagged", includeUntagged: true, want: []string{foreignArn, taggedArn}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			orphans, err := FindOrphanedAutoScalingConfigurations(ctx, apprunnerClient, cfnClient, nil, tc.includeUntagged)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			found := map[string]bool{}
+			for _, orphan := range orphans {
+				found[orphan.AutoScalingConfigurationArn] = true
+			}
+			if len(found) != len(tc.want) {
+				t.Errorf("orphans = %d, want %d", len(found), len(tc.want))
+			}
+			for _, arn := range tc.want {
+				if !found[arn] {
+					t.Errorf("%s not found", arn)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package apprunnerops
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+const (
+	ActionPause  = "pause"
+	ActionResume = "resume"
+)
+
+type PauseResumeResult struct {
+	ServiceArn string
+	Status     string
+	Skipped    bool
+	Error      string `json:",omitempty"`
+}
+
+// PauseOrResumeServices pauses or resumes all services of the stack and waits for the operations.
+// Services already in the target state are skipped, and a failure of one service does not stop the others.
+func PauseOrResumeServices(ctx context.Context, apprunnerClient *apprunner.Client, cfnClient *cloudformation.Client, stackName string, action string) ([]*PauseResumeResult, error) {
+	var targetStatus types.ServiceStatus
+	switch action {
+	case ActionPause:
+		targetStatus = types.ServiceStatusPaused
+	case ActionResume:
+		targetStatus = types.ServiceStatusRunning
+	default:
+		return nil, fmt.Errorf("Action must be %s or %s: %s", ActionPause, ActionResume, action)
+	}
+
+	serviceArns, err := GetServiceArns(ctx, cfnClient, stackName, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(serviceArns) == 0 {
+		return nil, fmt.Errorf("Service Arns not found")
+	}
+
+	results := make([]*PauseResumeResult, len(serviceArns))
+	var wg sync.WaitGroup
+	for i, serviceArn := range serviceArns {
+		i, serviceArn := i, serviceArn
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			result := &PauseResumeResult{ServiceArn: serviceArn}
+			skipped, err := pauseOrResumeService(ctx, apprunnerClient, serviceArn, action, targetStatus)
+			if err != nil {
+				result.Error = err.Error()
+			} else {
+				result.Status = string(targetStatus)
+				result.Skipped = skipped
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Error != "" {
+			return results, fmt.Errorf("Failed to %s some services", action)
+		}
+	}
+
+	return results, nil
+}
+
+func pauseOrResumeService(ctx context.Context, apprunnerClient *apprunner.Client, serviceArn string, action string, targetStatus types.ServiceStatus) (bool, error) {
+	describeServiceOutput, err := apprunnerClient.DescribeService(ctx, &apprunner.DescribeServiceInput{
+		ServiceArn: aws.String(serviceArn),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	currentStatus := describeServiceOutput.Service.Status
+	if currentStatus == targetStatus {
+		return true, nil
+	}
+	if currentStatus == types.ServiceStatusOperationInProgress {
+		return false, fmt.Errorf("Another operation is in progress: %s", serviceArn)
+	}
+
+	var operationId *string
+	if action == ActionPause {
+		output, err := apprunnerClient.PauseService(ctx, &apprunner.PauseServiceInput{
+			ServiceArn: aws.String(serviceArn),
+		})
+		if err != nil {
+			return false, err
+		}
+		operationId = output.OperationId
+	} else {
+		output, err := apprunnerClient.ResumeService(ctx, &apprunner.ResumeServiceInput{
+			ServiceArn: aws.String(serviceArn),
+		})
+		if err != nil {
+			return false, err
+		}
+		operationId = output.OperationId
+	}
+
+	return false, WaitOperation(ctx, apprunnerClient, aws.ToString(operationId), serviceArn)
+}
@@ -0,0 +1,156 @@
+package apprunnerops
+
+import (
+	"context"
+	"go-cdk-go-managed-apprunner/custom/fakeapi"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+const testStackName = "AppRunnerGoStack"
+
+func newTestClients(t *testing.T) (*apprunner.Client, *cloudformation.Client, *fakeapi.Server, []string) {
+	t.Helper()
+
+	server := fakeapi.NewServer()
+	t.Cleanup(server.Close)
+	server.OperationDuration = 30 * time.Millisecond
+	serviceArns := server.AddStack(testStackName, "L2", "L1", "Api")
+
+	pollInterval := OperationPollInterval
+	OperationPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { OperationPollInterval = pollInterval })
+
+	cfg := server.Config()
+	return apprunner.NewFromConfig(cfg), cloudformation.NewFromConfig(cfg), server, serviceArns
+}
+
+func countCalls(server *fakeapi.Server, call string) int {
+	count := 0
+	for _, c := range server.Calls() {
+		if c == call {
+			count++
+		}
+	}
+	return count
+}
+
+func assertServiceStatus(t *testing.T, server *fakeapi.Server, serviceArns []string, want string) {
+	t.Helper()
+
+	for _, serviceArn := range serviceArns {
+		service, _ := server.Service(serviceArn)
+		if service.Status != want {
+			t.Errorf("%s: Status = %s, want %s", serviceArn, service.Status, want)
+		}
+	}
+}
+
+func TestPauseOrResumeServices(t *testing.T) {
+	apprunnerClient, cfnClient, server, serviceArns := newTestClients(t)
+	ctx := context.Background()
+
+	// A service already paused, e.g. from the console, is skipped.
+	server.SetServiceStatus(serviceArns[0], "PAUSED")
+
+	results, err := PauseOrResumeServices(ctx, apprunnerClient, cfnClient, testStackName, ActionPause)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != len(serviceArns) {
+		t.Fatalf("%d results, want %d", len(results), len(serviceArns))
+	}
+	for _, result := range results {
+		if result.Status != "PAUSED" || result.Error != "" {
+			t.Errorf("%s: Status = %s, Error = %q, want PAUSED", result.ServiceArn, result.Status, result.Error)
+		}
+		if wantSkipped := result.ServiceArn == serviceArns[0]; result.Skipped != wantSkipped {
+			t.Errorf("%s: Skipped = %v, want %v", result.ServiceArn, result.Skipped, wantSkipped)
+		}
+	}
+	assertServiceStatus(t, server, serviceArns, "PAUSED")
+	if got := countCalls(server, "AppRunner.PauseService"); got != 2 {
+		t.Errorf("PauseService calls = %d, want 2", got)
+	}
+
+	// Pausing again skips all of them.
+	results, err = PauseOrResumeServices(ctx, apprunnerClient, cfnClient, testStackName, ActionPause)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if !result.Skipped {
+			t.Errorf("%s not skipped on the second pause", result.ServiceArn)
+		}
+	}
+	if got := countCalls(server, "AppRunner.PauseService"); got != 2 {
+		t.Errorf("PauseService calls = %d after the second pause, want 2", got)
+	}
+
+	results, err = PauseOrResumeServices(ctx, apprunnerClient, cfnClient, testStackName, ActionResume)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, result := range results {
+		if result.Status != "RUNNING" || result.Skipped {
+			t.Errorf("%s: Status = %s, Skipped = %v, want resumed", result.ServiceArn, result.Status, result.Skipped)
+		}
+	}
+	assertServiceStatus(t, server, serviceArns, "RUNNING")
+	if got := countCalls(server, "AppRunner.ResumeService"); got != len(serviceArns) {
+		t.Errorf("ResumeService calls = %d, want %d", got, len(serviceArns))
+	}
+}
+
+func TestPauseOrResumeServicesFailure(t *testing.T) {
+	apprunnerClient, cfnClient, server, serviceArns := newTestClients(t)
+	ctx := context.Background()
+
+	t.Run("failed operation", func(t *testing.T) {
+		server.FailOperations = true
+		defer func() { server.FailOperations = false }()
+
+		results, err := PauseOrResumeServices(ctx, apprunnerClient, cfnClient, testStackName, ActionPause)
+		if err == nil {
+			t.Fatal("no error for the failed operations")
+		}
+		for _, result := range results {
+			if result.Error == "" {
+				t.Errorf("%s: no error for the failed operation", result.ServiceArn)
+			}
+		}
+		assertServiceStatus(t, server, serviceArns, "RUNNING")
+	})
+
+	t.Run("failed request", func(t *testing.T) {
+		server.FailRequest("PauseService", "ThrottlingException")
+		defer server.ClearFailures()
+		server.SetServiceStatus(serviceArns[1], "PAUSED")
+
+		results, err := PauseOrResumeServices(ctx, apprunnerClient, cfnClient, testStackName, ActionPause)
+		if err == nil {
+			t.Fatal("no error for the failed PauseService")
+		}
+		// The failures of the others do not stop the skip of the paused service.
+		for _, result := range results {
+			if result.ServiceArn == serviceArns[1] {
+				if !result.Skipped || result.Error != "" {
+					t.Errorf("%s: Skipped = %v, Error = %q, want skipped", result.ServiceArn, result.Skipped, result.Error)
+				}
+				continue
+			}
+			if result.Error == "" {
+				t.Errorf("%s: no error for the failed PauseService", result.ServiceArn)
+			}
+		}
+	})
+
+	t.Run("invalid action", func(t *testing.T) {
+		if _, err := PauseOrResumeServices(ctx, apprunnerClient, cfnClient, testStackName, "stop"); err == nil {
+			t.Error("no error for the invalid action")
+		}
+	})
+}
@@ -0,0 +1,620 @@
+// Package autoscaling is the handler of the custom resource (Custom::AutoScalingConfiguration)
+// that manages the revisions of the AutoScalingConfiguration of the services.
+package autoscaling
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go-cdk-go-managed-apprunner/custom/apprunnerops"
+	"go-cdk-go-managed-apprunner/custom/logging"
+	"go-cdk-go-managed-apprunner/custom/metrics"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner/types"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+type InputProps struct {
+	autoScalingConfigurationName        string
+	maxConcurrency                      int
+	maxSize                             int
+	minSize                             int
+	stackName                           string
+	serviceArnExportNames               []string
+	retainOnDelete                      bool
+	fallbackAutoScalingConfigurationArn string
+	tags                                map[string]string
+	accountDefault                      bool
+}
+
+// Handler handles the events of Custom::AutoScalingConfiguration with the clients,
+// which are pointed at a fake API by custom/localrun.
+type Handler struct {
+	apprunnerClient *apprunner.Client
+	cfnClient       *cloudformation.Client
+	logger          *logging.Logger
+	// metricsWriter is where the EMF metrics of the requests are written in metricsNamespace.
+	metricsWriter    io.Writer
+	metricsNamespace string
+}
+
+func NewHandler(apprunnerClient *apprunner.Client, cfnClient *cloudformation.Client) *Handler {
+	metricsNamespace := os.Getenv("METRICS_NAMESPACE")
+	if metricsNamespace == "" {
+		metricsNamespace = metrics.DefaultNamespace
+	}
+
+	return &Handler{
+		apprunnerClient:  apprunnerClient,
+		cfnClient:        cfnClient,
+		logger:           logging.New(os.Stdout),
+		metricsWriter:    os.Stdout,
+		metricsNamespace: metricsNamespace,
+	}
+}
+
+// legacyPhysicalResourceID is the physical ID of the resources created before the revision ARN became the physical ID.
+// It is also returned by a failed Create, so that Delete of the rollback cleans up by the name and the values.
+const legacyPhysicalResourceID = "AutoScalingConfiguration"
+
+// nativeResourceTagKey and nativeResourceTagValue mark the revisions of AWS::AppRunner::AutoScalingConfiguration (cdk),
+// which can have the same name and values as the custom resource's while migrating to the native resource.
+const (
+	nativeResourceTagKey   = "AutoScalingConfigurationResource"
+	nativeResourceTagValue = "NATIVE"
+)
+
+// HandleRequest is idempotent for the retries of CloudFormation and the events of rollbacks.
+// The physical ID is the ARN of the revision with the name and the values of the properties,
+// so a changed value returns a new physical ID: CloudFormation moves the services to the new revision
+// through AutoScalingConfigurationArn and then sends Delete with the old revision ARN.
+// A rollback Update, with the old values in ResourceProperties, returns the old revision which is not deleted yet.
+//
+// Every log of the request, including the App Runner calls and the operations, has the correlation IDs of the event.
+// The error, which cfn.LambdaWrap sends as Reason, points at the log stream of the request.
+// The EMF metrics of the request (e.g. Failures) are written when it ends.
+func (h *Handler) HandleRequest(ctx context.Context, event cfn.Event) (string, map[string]interface{}, error) {
+	logger := h.logger.With(
+		"RequestId", event.RequestID,
+		"StackId", event.StackID,
+		"LogicalResourceId", event.LogicalResourceID,
+		"RequestType", string(event.RequestType),
+	)
+	ctx = logging.NewContext(ctx, logger)
+	recorder := metrics.New(h.metricsWriter, h.metricsNamespace, stackNameOf(event.StackID), string(event.RequestType))
+	ctx = metrics.NewContext(ctx, recorder)
+
+	start := time.Now()
+	logger.Info("request started",
+		"PhysicalResourceId", event.PhysicalResourceID,
+		"ResourceProperties", event.ResourceProperties,
+		"OldResourceProperties", event.OldResourceProperties,
+	)
+
+	physicalResourceID, data, err := h.handleRequest(ctx, event)
+	if err != nil {
+		recorder.Add(metrics.Failures, 1)
+	}
+	recorder.Flush(time.Since(start))
+	if err != nil {
+		logger.Error("request failed",
+			"PhysicalResourceId", physicalResourceID,
+			"DurationMs", time.Since(start).Milliseconds(),
+			"Error", err.Error(),
+		)
+		return physicalResourceID, data, fmt.Errorf("%w%s", err, logPointer(event))
+	}
+
+	logger.Info("request succeeded",
+		"PhysicalResourceId", physicalResourceID,
+		"Data", data,
+		"DurationMs", time.Since(start).Milliseconds(),
+	)
+	return physicalResourceID, data, nil
+}
+
+// stackNameOf returns the stack name of the stack ID (arn:aws:cloudformation:region:account:stack/name/id).
+func stackNameOf(stackID string) string {
+	parts := strings.Split(stackID, "/")
+	if len(parts) < 3 {
+		return stackID
+	}
+	return parts[1]
+}
+
+// logPointer returns where the logs of the event are, or empty outside of Lambda.
+func logPointer(event cfn.Event) string {
+	if lambdacontext.LogGroupName == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (CloudWatch Logs: %s %s, RequestId %s)", lambdacontext.LogGroupName, lambdacontext.LogStreamName, event.RequestID)
+}
+
+func (h *Handler) handleRequest(ctx context.Context, event cfn.Event) (physicalResourceID string, data map[string]interface{}, err error) {
+	// On errors, the physical ID of the event is kept not to make CloudFormation see a replacement.
+	physicalResourceID = event.PhysicalResourceID
+	if physicalResourceID == "" {
+		physicalResourceID = legacyPhysicalResourceID
+	}
+	data = make(map[string]interface{})
+
+	inputProps, err := convertInputParameters(event.ResourceProperties)
+	if err != nil {
+		return physicalResourceID, nil, err
+	}
+
+	switch event.RequestType {
+	case cfn.RequestCreate, cfn.RequestUpdate:
+		autoScalingConfigurationArn, err := h.ensureAutoScalingConfiguration(ctx, event, inputProps)
+		if err != nil {
+			return physicalResourceID, nil, err
+		}
+		if err := h.syncAccountDefault(ctx, event, autoScalingConfigurationArn, inputProps); err != nil {
+			return physicalResourceID, nil, err
+		}
+
+		data["AutoScalingConfigurationArn"] = autoScalingConfigurationArn
+		return autoScalingConfigurationArn, data, nil
+	case cfn.RequestDelete:
+		if inputProps.accountDefault && isRevisionArn(event.PhysicalResourceID) {
+			if err := h.restoreAccountDefault(ctx, []string{event.PhysicalResourceID}, inputProps); err != nil {
+				return physicalResourceID, nil, err
+			}
+		}
+		if isRevisionArn(event.PhysicalResourceID) {
+			err = h.deleteRevision(ctx, event.PhysicalResourceID, inputProps)
+		} else {
+			err = h.deleteLegacyRevisions(ctx, inputProps)
+		}
+		if err != nil {
+			return physicalResourceID, nil, err
+		}
+	}
+
+	return physicalResourceID, data, nil
+}
+
+// ensureAutoScalingConfiguration returns the revision with the name and the values, creating one only if there is none.
+// The tags of the properties are given to a created revision, or synced to an existing one.
+func (h *Handler) ensureAutoScalingConfiguration(ctx context.Context, event cfn.Event, inputProps *InputProps) (string, error) {
+	// When only StackName or ServiceArnExportNames changed, the current revision is kept as is.
+	if event.RequestType == cfn.RequestUpdate && isRevisionArn(event.PhysicalResourceID) {
+		oldInputProps, err := convertInputParameters(event.OldResourceProperties)
+		if err == nil && oldInputProps.sameConfiguration(inputProps) {
+			configuration, err := apprunnerops.DescribeAutoScalingConfiguration(ctx, h.apprunnerClient, event.PhysicalResourceID)
+			if err != nil && !isNotFound(err) {
+				return "", err
+			}
+			if err == nil && configuration.Status == types.AutoScalingConfigurationStatusActive {
+				logging.FromContext(ctx).Info("revision kept", "AutoScalingConfigurationArn", event.PhysicalResourceID)
+				return event.PhysicalResourceID, h.syncTags(ctx, event, event.PhysicalResourceID, inputProps)
+			}
+		}
+	}
+
+	reusedArn, err := apprunnerops.FindAutoScalingConfiguration(
+		ctx,
+		h.apprunnerClient,
+		inputProps.autoScalingConfigurationName,
+		inputProps.maxConcurrency,
+		inputProps.maxSize,
+		inputProps.minSize,
+	)
+	if err != nil {
+		return "", err
+	}
+	if reusedArn != "" {
+		logging.FromContext(ctx).Info("revision reused", "AutoScalingConfigurationArn", reusedArn)
+		return reusedArn, h.syncTags(ctx, event, reusedArn, inputProps)
+	}
+
+	autoScalingConfigurationArn, err := apprunnerops.CreateAutoScalingConfiguration(
+		ctx,
+		h.apprunnerClient,
+		inputProps.autoScalingConfigurationName,
+		inputProps.maxConcurrency,
+		inputProps.maxSize,
+		inputProps.minSize,
+		inputProps.tags,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	logging.FromContext(ctx).Info("revision created", "AutoScalingConfigurationArn", autoScalingConfigurationArn)
+	return autoScalingConfigurationArn, nil
+}
+
+// syncTags makes the revision have the tags of the properties.
+// The tags removed from the properties on Update are untagged, and the other tags of the revision are kept.
+func (h *Handler) syncTags(ctx context.Context, event cfn.Event, autoScalingConfigurationArn string, inputProps *InputProps) error {
+	removedTagKeys := []string{}
+	if event.RequestType == cfn.RequestUpdate {
+		if oldInputProps, err := convertInputParameters(event.OldResourceProperties); err == nil {
+			for key := range oldInputProps.tags {
+				removedTagKeys = append(removedTagKeys, key)
+			}
+		}
+	}
+	if len(inputProps.tags) == 0 && len(removedTagKeys) == 0 {
+		return nil
+	}
+
+	return apprunnerops.SyncTags(ctx, h.apprunnerClient, autoScalingConfigurationArn, inputProps.tags, removedTagKeys)
+}
+
+// syncAccountDefault makes the revision the account default with AccountDefault.
+// When AccountDefault is turned off on Update, the account default is restored if it is still the revision of the resource.
+// A revision replaced on Update stops being the default here, before CloudFormation sends Delete with it.
+func (h *Handler) syncAccountDefault(ctx context.Context, event cfn.Event, autoScalingConfigurationArn string, inputProps *InputProps) error {
+	if inputProps.accountDefault {
+		defaultArn, err := apprunnerops.GetDefaultAutoScalingConfigurationArn(ctx, h.apprunnerClient)
+		if err != nil {
+			return err
+		}
+		if defaultArn == autoScalingConfigurationArn {
+			return nil
+		}
+		if err := apprunnerops.UpdateDefaultAutoScalingConfiguration(ctx, h.apprunnerClient, autoScalingConfigurationArn); err != nil {
+			return err
+		}
+		logging.FromContext(ctx).Info("account default changed", "From", defaultArn, "To", autoScalingConfigurationArn)
+		return nil
+	}
+
+	if event.RequestType != cfn.RequestUpdate {
+		return nil
+	}
+	oldInputProps, err := convertInputParameters(event.OldResourceProperties)
+	if err != nil || !oldInputProps.accountDefault {
+		return nil
+	}
+	revisionArns := []string{autoScalingConfigurationArn}
+	if isRevisionArn(event.PhysicalResourceID) {
+		revisionArns = append(revisionArns, event.PhysicalResourceID)
+	}
+	return h.restoreAccountDefault(ctx, revisionArns, inputProps)
+}
+
+// restoreAccountDefault gives the account default back to FallbackAutoScalingConfigurationArn or DefaultConfiguration,
+// if it is one of the revisions of the resource. Otherwise the default has been changed outside of the stack and is left as is.
+func (h *Handler) restoreAccountDefault(ctx context.Context, revisionArns []string, inputProps *InputProps) error {
+	defaultArn, err := apprunnerops.GetDefaultAutoScalingConfigurationArn(ctx, h.apprunnerClient)
+	if err != nil {
+		return err
+	}
+	owned := false
+	for _, revisionArn := range revisionArns {
+		if revisionArn == defaultArn {
+			owned = true
+		}
+	}
+	if !owned {
+		return nil
+	}
+
+	restoredArn := inputProps.fallbackAutoScalingConfigurationArn
+	if restoredArn == "" {
+		restoredArn, err = apprunnerops.GetDefaultConfigurationArn(ctx, h.apprunnerClient)
+		if err != nil {
+			return fmt.Errorf("restoring the account default AutoScalingConfiguration: %v: set FallbackAutoScalingConfigurationArn to restore", err)
+		}
+	}
+	if err := apprunnerops.UpdateDefaultAutoScalingConfiguration(ctx, h.apprunnerClient, restoredArn); err != nil {
+		return err
+	}
+
+	logging.FromContext(ctx).Info("account default restored", "From", defaultArn, "To", restoredArn)
+	return nil
+}
+
+// deleteRevision deletes the revision of the physical ID unless RetainOnDelete.
+// The surviving services of the stack still on it, e.g. moved by scheduled scaling or retained on the stack deletion,
+// are moved first to the newest other revision of the name, or to the fallback if there is none,
+// so that the deletion does not fail and leave the stack in DELETE_FAILED.
+func (h *Handler) deleteRevision(ctx context.Context, autoScalingConfigurationArn string, inputProps *InputProps) error {
+	logger := logging.FromContext(ctx).With("AutoScalingConfigurationArn", autoScalingConfigurationArn)
+	if inputProps.retainOnDelete {
+		logger.Info("revision retained")
+		return nil
+	}
+
+	configuration, err := apprunnerops.DescribeAutoScalingConfiguration(ctx, h.apprunnerClient, autoScalingConfigurationArn)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if configuration.Status != types.AutoScalingConfigurationStatusActive {
+		return nil
+	}
+
+	serviceAutoScalingConfigurationArns, err := apprunnerops.GetServiceAutoScalingConfigurationArns(ctx, h.apprunnerClient, h.cfnClient, inputProps.stackName, inputProps.serviceArnExportNames)
+	if err != nil {
+		return err
+	}
+	attachedServiceArns := []string{}
+	for serviceArn, arn := range serviceAutoScalingConfigurationArns {
+		if arn == autoScalingConfigurationArn {
+			attachedServiceArns = append(attachedServiceArns, serviceArn)
+		}
+	}
+
+	if len(attachedServiceArns) > 0 {
+		destinationArn, err := h.replacementAutoScalingConfigurationArn(ctx, autoScalingConfigurationArn, inputProps)
+		if err != nil {
+			return err
+		}
+		logger.Info("moving services off the revision", "ServiceArns", attachedServiceArns, "DestinationArn", destinationArn)
+		if err := apprunnerops.UpdateServices(ctx, h.apprunnerClient, attachedServiceArns, destinationArn); err != nil {
+			return err
+		}
+	}
+
+	err = apprunnerops.DeleteAutoScalingConfiguration(ctx, h.apprunnerClient, autoScalingConfigurationArn)
+	if isNotFound(err) {
+		return nil
+	}
+	var invalidRequest *types.InvalidRequestException
+	if errors.As(err, &invalidRequest) {
+		return fmt.Errorf("%v: the AutoScalingConfiguration may be used by services outside the stack, set RetainOnDelete to keep it", err)
+	}
+	if err != nil {
+		return err
+	}
+
+	logger.Info("revision deleted")
+	return nil
+}
+
+// replacementAutoScalingConfigurationArn returns the newest other revision of the name,
+// otherwise FallbackAutoScalingConfigurationArn or the account default.
+func (h *Handler) replacementAutoScalingConfigurationArn(ctx context.Context, autoScalingConfigurationArn string, inputProps *InputProps) (string, error) {
+	revisions, err := apprunnerops.ListAutoScalingConfigurationRevisions(ctx, h.apprunnerClient, inputProps.autoScalingConfigurationName)
+	if err != nil {
+		return "", err
+	}
+	for _, revision := range revisions {
+		if *revision.AutoScalingConfigurationArn != autoScalingConfigurationArn {
+			return *revision.AutoScalingConfigurationArn, nil
+		}
+	}
+
+	if inputProps.fallbackAutoScalingConfigurationArn != "" {
+		return inputProps.fallbackAutoScalingConfigurationArn, nil
+	}
+	defaultArn, err := apprunnerops.GetDefaultAutoScalingConfigurationArn(ctx, h.apprunnerClient)
+	if err != nil {
+		return "", err
+	}
+	if defaultArn == autoScalingConfigurationArn {
+		// The revision is the account default changed outside of the resource.
+		return apprunnerops.GetDefaultConfigurationArn(ctx, h.apprunnerClient)
+	}
+	return defaultArn, nil
+}
+
+// deleteLegacyRevisions deletes the revisions with the name and the values for a legacy physical ID.
+// In the cleanup after the first Update of a legacy resource, the revisions used by the services of the stack are kept,
+// because the revision of the new physical ID may have the same values as the old one.
+// Otherwise, e.g. on the stack deletion, each revision is deleted by deleteRevision,
+// which first moves the surviving or retained services off it.
+// The revisions of the native resource are kept too, which replaces the custom resource on the migration.
+func (h *Handler) deleteLegacyRevisions(ctx context.Context, inputProps *InputProps) error {
+	if inputProps.retainOnDelete {
+		return nil
+	}
+
+	autoScalingConfigurationArns, err := apprunnerops.FindAutoScalingConfigurations(
+		ctx,
+		h.apprunnerClient,
+		inputProps.autoScalingConfigurationName,
+		inputProps.maxConcurrency,
+		inputProps.maxSize,
+		inputProps.minSize,
+	)
+	if err != nil || len(autoScalingConfigurationArns) == 0 {
+		return err
+	}
+
+	cleanup, err := h.inUpdateCleanup(ctx, inputProps.stackName)
+	if err != nil {
+		return err
+	}
+	attached := map[string]bool{}
+	if cleanup {
+		serviceAutoScalingConfigurationArns, err := apprunnerops.GetServiceAutoScalingConfigurationArns(ctx, h.apprunnerClient, h.cfnClient, inputProps.stackName, inputProps.serviceArnExportNames)
+		if err != nil {
+			return err
+		}
+		for _, arn := range serviceAutoScalingConfigurationArns {
+			attached[arn] = true
+		}
+	}
+
+	for _, autoScalingConfigurationArn := range autoScalingConfigurationArns {
+		if attached[autoScalingConfigurationArn] {
+			continue
+		}
+		tags, err := apprunnerops.ListTags(ctx, h.apprunnerClient, autoScalingConfigurationArn)
+		if isNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if tags[nativeResourceTagKey] == nativeResourceTagValue {
+			logging.FromContext(ctx).Info("revision of the native resource kept", "AutoScalingConfigurationArn", autoScalingConfigurationArn)
+			continue
+		}
+
+		if err := h.deleteRevision(ctx, autoScalingConfigurationArn, inputProps); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inUpdateCleanup returns whether the stack is in the cleanup after an update or its rollback,
+// where CloudFormation deletes the resources replaced by new physical IDs.
+func (h *Handler) inUpdateCleanup(ctx context.Context, stackName string) (bool, error) {
+	output, err := h.cfnClient.DescribeStacks(ctx, &cloudformation.DescribeStacksInput{
+		StackName: aws.String(stackName),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	for _, stack := range output.Stacks {
+		switch stack.StackStatus {
+		case "UPDATE_COMPLETE_CLEANUP_IN_PROGRESS", "UPDATE_ROLLBACK_COMPLETE_CLEANUP_IN_PROGRESS":
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func isRevisionArn(physicalResourceID string) bool {
+	return strings.HasPrefix(physicalResourceID, "arn:") && strings.Contains(physicalResourceID, ":autoscalingconfiguration/")
+}
+
+func isNotFound(err error) bool {
+	var notFound *types.ResourceNotFoundException
+	return errors.As(err, &notFound)
+}
+
+// sameConfiguration reports whether the properties make the same revision.
+func (p *InputProps) sameConfiguration(other *InputProps) bool {
+	return p.autoScalingConfigurationName == other.autoScalingConfigurationName &&
+		p.maxConcurrency == other.maxConcurrency &&
+		p.maxSize == other.maxSize &&
+		p.minSize == other.minSize
+}
+
+func convertInputParameters(resourceProperties map[string]interface{}) (*InputProps, error) {
+	autoScalingConfigurationName, ok := resourceProperties["AutoScalingConfigurationName"].(string)
+	if !ok {
+		return nil, fmt.Errorf("AutoScalingConfigurationName Assertion Error: %v", resourceProperties["AutoScaling"])
+	}
+
+	maxConcurrencyInput, ok := resourceProperties["MaxConcurrency"].(string)
+	if !ok {
+		return nil, fmt.Errorf("MaxConcurrency Assertion Error: %v", resourceProperties["MaxConcurrency"])
+	}
+	maxConcurrency, err := strconv.Atoi(maxConcurrencyInput)
+	if err != nil {
+		return nil, fmt.Errorf("MaxConcurrency Convert Error: %v", maxConcurrency)
+	}
+
+	maxSizeInput, ok := resourceProperties["MaxSize"].(string)
+	if !ok {
+		return nil, fmt.Errorf("MaxSize Assertion Error: %v", resourceProperties["MaxSize"])
+	}
+	maxSize, err := strconv.Atoi(maxSizeInput)
+	if err != nil {
+		return nil, fmt.Errorf("MaxSize Convert Error: %v", maxSize)
+	}
+
+	minSizeInput, ok := resourceProperties["MinSize"].(string)
+	if !ok {
+		return nil, fmt.Errorf("MinSize Assertion Error: %v", resourceProperties["MinSize"])
+	}
+	minSize, err := strconv.Atoi(minSizeInput)
+	if err != nil {
+		return nil, fmt.Errorf("MinSize Convert Error: %v", minSize)
+	}
+
+	stackName, ok := resourceProperties["StackName"].(string)
+	if !ok {
+		return nil, fmt.Errorf("StackName Assertion Error: %v", resourceProperties["StackName"])
+	}
+
+	// ServiceArnExportNames is optional for the resources created before it was added, which target all the services.
+	serviceArnExportNames := []string{}
+	if serviceArnExportNamesInput, ok := resourceProperties["ServiceArnExportNames"]; ok {
+		exportNames, ok := serviceArnExportNamesInput.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ServiceArnExportNames Assertion Error: %v", serviceArnExportNamesInput)
+		}
+		for _, exportName := range exportNames {
+			name, ok := exportName.(string)
+			if !ok {
+				return nil, fmt.Errorf("ServiceArnExportNames Assertion Error: %v", serviceArnExportNamesInput)
+			}
+			serviceArnExportNames = append(serviceArnExportNames, name)
+		}
+	}
+
+	retainOnDelete := false
+	if retainOnDeleteInput, ok := resourceProperties["RetainOnDelete"]; ok {
+		retainOnDeleteString, ok := retainOnDeleteInput.(string)
+		if !ok {
+			return nil, fmt.Errorf("RetainOnDelete Assertion Error: %v", retainOnDeleteInput)
+		}
+		retainOnDelete, err = strconv.ParseBool(retainOnDeleteString)
+		if err != nil {
+			return nil, fmt.Errorf("RetainOnDelete Convert Error: %v", retainOnDeleteString)
+		}
+	}
+
+	fallbackAutoScalingConfigurationArn := ""
+	if fallbackInput, ok := resourceProperties["FallbackAutoScalingConfigurationArn"]; ok {
+		fallbackAutoScalingConfigurationArn, ok = fallbackInput.(string)
+		if !ok {
+			return nil, fmt.Errorf("FallbackAutoScalingConfigurationArn Assertion Error: %v", fallbackInput)
+		}
+	}
+
+	// Tags is optional for the resources created before it was added.
+	tags := map[string]string{}
+	if tagsInput, ok := resourceProperties["Tags"]; ok {
+		tagValues, ok := tagsInput.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Tags Assertion Error: %v", tagsInput)
+		}
+		for key, value := range tagValues {
+			tagValue, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("Tags Assertion Error: %v", tagsInput)
+			}
+			tags[key] = tagValue
+		}
+	}
+
+	// AccountDefault makes the revision the default AutoScalingConfiguration of the account.
+	accountDefault := false
+	if accountDefaultInput, ok := resourceProperties["AccountDefault"]; ok {
+		accountDefaultString, ok := accountDefaultInput.(string)
+		if !ok {
+			return nil, fmt.Errorf("AccountDefault Assertion Error: %v", accountDefaultInput)
+		}
+		accountDefault, err = strconv.ParseBool(accountDefaultString)
+		if err != nil {
+			return nil, fmt.Errorf("AccountDefault Convert Error: %v", accountDefaultString)
+		}
+	}
+
+	return &InputProps{
+		autoScalingConfigurationName:        autoScalingConfigurationName,
+		maxConcurrency:                      maxConcurrency,
+		maxSize:                             maxSize,
+		minSize:                             minSize,
+		stackName:                           stackName,
+		serviceArnExportNames:               serviceArnExportNames,
+		retainOnDelete:                      retainOnDelete,
+		fallbackAutoScalingConfigurationArn: fallbackAutoScalingConfigurationArn,
+		tags:                                tags,
+		accountDefault:                      accountDefault,
+	}, nil
+}
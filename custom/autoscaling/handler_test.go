@@ -0,0 +1,1050 @@
+package autoscaling
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"go-cdk-go-managed-apprunner/custom/apprunnerops"
+	"go-cdk-go-managed-apprunner/custom/fakeapi"
+	"go-cdk-go-managed-apprunner/custom/logging"
+	"go-cdk-go-managed-apprunner/custom/metrics"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-lambda-go/lambdacontext"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+const testStackName = "AppRunnerGoStack"
+
+func newTestHandler(t *testing.T) (*Handler, *fakeapi.Server, []string) {
+	t.Helper()
+
+	server := fakeapi.NewServer()
+	t.Cleanup(server.Close)
+	serviceArns := server.AddStack(testStackName, "L2", "L1")
+
+	pollInterval := apprunnerops.OperationPollInterval
+	apprunnerops.OperationPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { apprunnerops.OperationPollInterval = pollInterval })
+
+	cfg := server.Config()
+	handler := NewHandler(apprunner.NewFromConfig(cfg), cloudformation.NewFromConfig(cfg))
+	handler.logger = logging.New(io.Discard)
+	handler.metricsWriter = io.Discard
+	return handler, server, serviceArns
+}
+
+func newTestProperties(maxConcurrency string, maxSize string, minSize string) map[string]interface{} {
+	return map[string]interface{}{
+		"AutoScalingConfigurationName": testStackName,
+		"MaxConcurrency":               maxConcurrency,
+		"MaxSize":                      maxSize,
+		"MinSize":                      minSize,
+		"StackName":                    testStackName,
+	}
+}
+
+func newTestEvent(requestType cfn.RequestType, physicalResourceID string, properties map[string]interface{}, oldProperties map[string]interface{}) cfn.Event {
+	return cfn.Event{
+		RequestType:           requestType,
+		LogicalResourceID:     "AutoScalingConfiguration",
+		PhysicalResourceID:    physicalResourceID,
+		ResourceType:          "Custom::AutoScalingConfiguration",
+		ResourceProperties:    properties,
+		OldResourceProperties: oldProperties,
+	}
+}
+
+// activeConfigurations returns the ACTIVE revisions of the stack's AutoScalingConfiguration.
+func activeConfigurations(server *fakeapi.Server) []fakeapi.AutoScalingConfiguration {
+	configurations := []fakeapi.AutoScalingConfiguration{}
+	for _, configuration := range server.AutoScalingConfigurations() {
+		if configuration.Name == testStackName && configuration.Status == "ACTIVE" {
+			configurations = append(configurations, configuration)
+		}
+	}
+	return configurations
+}
+
+func countCalls(server *fakeapi.Server, call string) int {
+	count := 0
+	for _, c := range server.Calls() {
+		if c == call {
+			count++
+		}
+	}
+	return count
+}
+
+// attach moves the services to the revision as CloudFormation does after the custom resource.
+func attach(server *fakeapi.Server, serviceArns []string, autoScalingConfigurationArn string) {
+	for _, serviceArn := range serviceArns {
+		server.SetServiceAutoScalingConfiguration(serviceArn, autoScalingConfigurationArn)
+	}
+}
+
+func assertServicesOn(t *testing.T, server *fakeapi.Server, serviceArns []string, autoScalingConfigurationArn string) {
+	t.Helper()
+
+	for _, serviceArn := range serviceArns {
+		service, _ := server.Service(serviceArn)
+		if service.AutoScalingConfigurationArn != autoScalingConfigurationArn {
+			t.Errorf("%s is on %s, want %s", service.Name, service.AutoScalingConfigurationArn, autoScalingConfigurationArn)
+		}
+	}
+}
+
+func TestHandleRequestCreate(t *testing.T) {
+	handler, server, _ := newTestHandler(t)
+	ctx := context.Background()
+	event := newTestEvent(cfn.RequestCreate, "", newTestProperties("50", "5", "1"), nil)
+
+	physicalResourceID, data, err := handler.HandleRequest(ctx, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configurations := activeConfigurations(server)
+	if len(configurations) != 1 {
+		t.Fatalf("ACTIVE configurations = %d, want 1", len(configurations))
+	}
+	if physicalResourceID != configurations[0].Arn || data["AutoScalingConfigurationArn"] != configurations[0].Arn {
+		t.Errorf("PhysicalResourceId = %s, AutoScalingConfigurationArn = %v, want %s", physicalResourceID, data["AutoScalingConfigurationArn"], configurations[0].Arn)
+	}
+	if c := configurations[0]; c.MaxConcurrency != 50 || c.MaxSize != 5 || c.MinSize != 1 {
+		t.Errorf("configuration = %+v", c)
+	}
+
+	// A retry of Create returns the same revision.
+	retriedPhysicalResourceID, _, err := handler.HandleRequest(ctx, event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retriedPhysicalResourceID != physicalResourceID {
+		t.Errorf("PhysicalResourceId of the retry = %s, want %s", retriedPhysicalResourceID, physicalResourceID)
+	}
+	if got := countCalls(server, "AppRunner.CreateAutoScalingConfiguration"); got != 1 {
+		t.Errorf("CreateAutoScalingConfiguration calls = %d, want 1", got)
+	}
+}
+
+func TestHandleRequestUpdate(t *testing.T) {
+	handler, server, serviceArns := newTestHandler(t)
+	ctx := context.Background()
+	oldProperties := newTestProperties("50", "5", "1")
+	properties := newTestProperties("100", "10", "2")
+
+	oldPhysicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", oldProperties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attach(server, serviceArns, oldPhysicalResourceID)
+
+	updateEvent := newTestEvent(cfn.RequestUpdate, oldPhysicalResourceID, properties, oldProperties)
+	physicalResourceID, data, err := handler.HandleRequest(ctx, updateEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if physicalResourceID == oldPhysicalResourceID || data["AutoScalingConfigurationArn"] != physicalResourceID {
+		t.Errorf("PhysicalResourceId = %s, AutoScalingConfigurationArn = %v", physicalResourceID, data["AutoScalingConfigurationArn"])
+	}
+	// The services stay on the old revision until CloudFormation moves them.
+	assertServicesOn(t, server, serviceArns, oldPhysicalResourceID)
+	if got := countCalls(server, "AppRunner.UpdateService"); got != 0 {
+		t.Errorf("UpdateService calls = %d, want 0", got)
+	}
+
+	// A retry of Update returns the same revision.
+	if retriedPhysicalResourceID, _, err := handler.HandleRequest(ctx, updateEvent); err != nil || retriedPhysicalResourceID != physicalResourceID {
+		t.Errorf("PhysicalResourceId of the retry = %s (%v), want %s", retriedPhysicalResourceID, err, physicalResourceID)
+	}
+
+	attach(server, serviceArns, physicalResourceID)
+	if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, oldPhysicalResourceID, oldProperties, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	configurations := activeConfigurations(server)
+	if len(configurations) != 1 || configurations[0].Arn != physicalResourceID {
+		t.Fatalf("ACTIVE configurations = %+v, want %s", configurations, physicalResourceID)
+	}
+	if c := configurations[0]; c.MaxConcurrency != 100 || c.MaxSize != 10 || c.MinSize != 2 {
+		t.Errorf("configuration = %+v", c)
+	}
+}
+
+func TestHandleRequestUpdateWithoutConfigurationChange(t *testing.T) {
+	handler, server, _ := newTestHandler(t)
+	ctx := context.Background()
+	oldProperties := newTestProperties("50", "5", "1")
+	properties := newTestProperties("50", "5", "1")
+	properties["ServiceArnExportNames"] = []interface{}{testStackName + "AppRunnerServiceL1ServiceArn"}
+
+	oldPhysicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", oldProperties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	physicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestUpdate, oldPhysicalResourceID, properties, oldProperties))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if physicalResourceID != oldPhysicalResourceID {
+		t.Errorf("PhysicalResourceId = %s, want %s", physicalResourceID, oldPhysicalResourceID)
+	}
+	if got := countCalls(server, "AppRunner.CreateAutoScalingConfiguration"); got != 1 {
+		t.Errorf("CreateAutoScalingConfiguration calls = %d, want 1", got)
+	}
+}
+
+// TestHandleRequestCreateReusesRevisionOnLaterPage finds the revision with the values beyond the first page of the list.
+func TestHandleRequestCreateReusesRevisionOnLaterPage(t *testing.T) {
+	handler, server, _ := newTestHandler(t)
+	server.PageSize = 1
+	ctx := context.Background()
+	properties := newTestProperties("50", "5", "1")
+
+	physicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", properties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The newer revisions come first in the list.
+	server.AddAutoScalingConfiguration(testStackName, 80, 5, 1)
+	server.AddAutoScalingConfiguration(testStackName, 100, 5, 1)
+
+	gotID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", properties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotID != physicalResourceID {
+		t.Errorf("PhysicalResourceId = %s, want %s reused", gotID, physicalResourceID)
+	}
+	if got := countCalls(server, "AppRunner.CreateAutoScalingConfiguration"); got != 1 {
+		t.Errorf("CreateAutoScalingConfiguration calls = %d, want 1", got)
+	}
+}
+
+func TestHandleRequestDelete(t *testing.T) {
+	handler, server, _ := newTestHandler(t)
+	ctx := context.Background()
+	properties := newTestProperties("50", "5", "1")
+
+	physicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", properties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleteEvent := newTestEvent(cfn.RequestDelete, physicalResourceID, properties, nil)
+	if _, _, err := handler.HandleRequest(ctx, deleteEvent); err != nil {
+		t.Fatal(err)
+	}
+	if configurations := activeConfigurations(server); len(configurations) != 0 {
+		t.Errorf("ACTIVE configurations = %+v, want none", configurations)
+	}
+
+	// A retry of Delete succeeds.
+	if _, _, err := handler.HandleRequest(ctx, deleteEvent); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestHandleRequestRollback replays the events of an update which fails after the custom resource is updated:
+// Create, Update, the Update of the rollback with the old properties and the Delete of the rollback cleanup,
+// each sent twice as CloudFormation retries them.
+func TestHandleRequestRollback(t *testing.T) {
+	handler, server, serviceArns := newTestHandler(t)
+	ctx := context.Background()
+	oldProperties := newTestProperties("50", "5", "1")
+	properties := newTestProperties("100", "10", "2")
+
+	oldPhysicalResourceID := ""
+	newPhysicalResourceID := ""
+	steps := []struct {
+		name  string
+		event func() cfn.Event
+		after func(physicalResourceID string)
+	}{
+		{
+			name:  "Create",
+			event: func() cfn.Event { return newTestEvent(cfn.RequestCreate, "", oldProperties, nil) },
+			after: func(physicalResourceID string) {
+				oldPhysicalResourceID = physicalResourceID
+				attach(server, serviceArns, physicalResourceID)
+			},
+		},
+		{
+			name: "Update",
+			event: func() cfn.Event {
+				return newTestEvent(cfn.RequestUpdate, oldPhysicalResourceID, properties, oldProperties)
+			},
+			after: func(physicalResourceID string) {
+				newPhysicalResourceID = physicalResourceID
+				attach(server, serviceArns, physicalResourceID)
+				server.SetStackStatus(testStackName, "UPDATE_ROLLBACK_IN_PROGRESS")
+			},
+		},
+		{
+			name: "Update of the rollback",
+			event: func() cfn.Event {
+				return newTestEvent(cfn.RequestUpdate, newPhysicalResourceID, oldProperties, properties)
+			},
+			after: func(physicalResourceID string) {
+				if physicalResourceID != oldPhysicalResourceID {
+					t.Errorf("PhysicalResourceId of the rollback = %s, want %s", physicalResourceID, oldPhysicalResourceID)
+				}
+				attach(server, serviceArns, physicalResourceID)
+				server.SetStackStatus(testStackName, "UPDATE_ROLLBACK_COMPLETE_CLEANUP_IN_PROGRESS")
+			},
+		},
+		{
+			name:  "Delete of the rollback cleanup",
+			event: func() cfn.Event { return newTestEvent(cfn.RequestDelete, newPhysicalResourceID, properties, nil) },
+			after: func(physicalResourceID string) {},
+		},
+	}
+
+	for _, step := range steps {
+		for attempt := 0; attempt < 2; attempt++ {
+			physicalResourceID, _, err := handler.HandleRequest(ctx, step.event())
+			if err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+			if attempt == 0 {
+				step.after(physicalResourceID)
+			}
+		}
+	}
+
+	configurations := activeConfigurations(server)
+	if len(configurations) != 1 || configurations[0].Arn != oldPhysicalResourceID {
+		t.Fatalf("ACTIVE configurations = %+v, want %s", configurations, oldPhysicalResourceID)
+	}
+	assertServicesOn(t, server, serviceArns, oldPhysicalResourceID)
+	if got := countCalls(server, "AppRunner.CreateAutoScalingConfiguration"); got != 2 {
+		t.Errorf("CreateAutoScalingConfiguration calls = %d, want 2", got)
+	}
+	if got := countCalls(server, "AppRunner.UpdateService"); got != 0 {
+		t.Errorf("UpdateService calls = %d, want 0", got)
+	}
+}
+
+// TestHandleRequestFailedUpdate replays an update which fails in the custom resource and its rollback.
+func TestHandleRequestFailedUpdate(t *testing.T) {
+	handler, server, _ := newTestHandler(t)
+	ctx := context.Background()
+	oldProperties := newTestProperties("50", "5", "1")
+	properties := newTestProperties("100", "10", "2")
+
+	oldPhysicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", oldProperties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.FailRequest("CreateAutoScalingConfiguration", "ServiceQuotaExceededException")
+	physicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestUpdate, oldPhysicalResourceID, properties, oldProperties))
+	if err == nil {
+		t.Fatal("Update succeeded, want an error")
+	}
+	if physicalResourceID != oldPhysicalResourceID {
+		t.Errorf("PhysicalResourceId of the failed Update = %s, want %s", physicalResourceID, oldPhysicalResourceID)
+	}
+
+	physicalResourceID, _, err = handler.HandleRequest(ctx, newTestEvent(cfn.RequestUpdate, oldPhysicalResourceID, oldProperties, properties))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if physicalResourceID != oldPhysicalResourceID {
+		t.Errorf("PhysicalResourceId of the rollback = %s, want %s", physicalResourceID, oldPhysicalResourceID)
+	}
+	if configurations := activeConfigurations(server); len(configurations) != 1 {
+		t.Errorf("ACTIVE configurations = %+v, want 1", configurations)
+	}
+}
+
+// TestHandleRequestLegacyPhysicalResourceID covers the resources created with the physical ID "AutoScalingConfiguration".
+func TestHandleRequestLegacyPhysicalResourceID(t *testing.T) {
+	handler, server, serviceArns := newTestHandler(t)
+	ctx := context.Background()
+	oldProperties := newTestProperties("50", "5", "1")
+	properties := newTestProperties("50", "5", "1")
+	properties["ServiceArnExportNames"] = []interface{}{}
+
+	autoScalingConfigurationArn, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", oldProperties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attach(server, serviceArns, autoScalingConfigurationArn)
+
+	physicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestUpdate, legacyPhysicalResourceID, properties, oldProperties))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if physicalResourceID != autoScalingConfigurationArn {
+		t.Errorf("PhysicalResourceId = %s, want %s", physicalResourceID, autoScalingConfigurationArn)
+	}
+
+	// The cleanup Delete of the legacy physical ID keeps the revision used by the services.
+	server.SetStackStatus(testStackName, "UPDATE_COMPLETE_CLEANUP_IN_PROGRESS")
+	if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, legacyPhysicalResourceID, oldProperties, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if configurations := activeConfigurations(server); len(configurations) != 1 {
+		t.Errorf("ACTIVE configurations = %+v, want 1", configurations)
+	}
+
+	// Delete of the legacy physical ID on the stack deletion deletes the revision,
+	// even when it is not the latest revision of the name.
+	server.SetStackStatus(testStackName, "DELETE_IN_PROGRESS")
+	attach(server, serviceArns, server.DefaultAutoScalingConfigurationArn())
+	newerArn := server.AddAutoScalingConfiguration(testStackName, 80, 5, 1)
+	if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, legacyPhysicalResourceID, oldProperties, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if configurations := activeConfigurations(server); len(configurations) != 1 || configurations[0].Arn != newerArn {
+		t.Errorf("ACTIVE configurations = %+v, want only %s", configurations, newerArn)
+	}
+}
+
+// TestHandleRequestLegacyDeleteRetainedServices covers the stack deletion of a legacy resource
+// while a service survives on its revision, e.g. retained by its DeletionPolicy.
+func TestHandleRequestLegacyDeleteRetainedServices(t *testing.T) {
+	handler, server, serviceArns := newTestHandler(t)
+	ctx := context.Background()
+	server.OperationDuration = 30 * time.Millisecond
+	properties := newTestProperties("50", "5", "1")
+	properties["FallbackAutoScalingConfigurationArn"] = server.DefaultAutoScalingConfigurationArn()
+
+	autoScalingConfigurationArn, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", properties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attach(server, serviceArns, autoScalingConfigurationArn)
+
+	server.SetStackStatus(testStackName, "DELETE_IN_PROGRESS")
+	if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, legacyPhysicalResourceID, properties, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertServicesOn(t, server, serviceArns, server.DefaultAutoScalingConfigurationArn())
+	if configurations := activeConfigurations(server); len(configurations) != 0 {
+		t.Errorf("ACTIVE configurations = %+v, want none", configurations)
+	}
+}
+
+// TestHandleRequestServiceArnExportNames covers a resource of a single service,
+// whose deletion does not look at the other services of the stack.
+func TestHandleRequestServiceArnExportNames(t *testing.T) {
+	handler, server, serviceArns := newTestHandler(t)
+	ctx := context.Background()
+	server.OperationDuration = 30 * time.Millisecond
+	properties := newTestProperties("50", "5", "1")
+	properties["ServiceArnExportNames"] = []interface{}{testStackName + "AppRunnerServiceL1ServiceArn"}
+
+	autoScalingConfigurationArn, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", properties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attach(server, serviceArns[1:], autoScalingConfigurationArn)
+
+	calls := countCalls(server, "AppRunner.DescribeService")
+	if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, autoScalingConfigurationArn, properties, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := countCalls(server, "AppRunner.DescribeService") - calls; got != 1 {
+		t.Errorf("%d DescribeService calls, want 1 for the service of the export name", got)
+	}
+	assertServicesOn(t, server, serviceArns[1:], server.DefaultAutoScalingConfigurationArn())
+	if configurations := activeConfigurations(server); len(configurations) != 0 {
+		t.Errorf("ACTIVE configurations = %+v, want none", configurations)
+	}
+}
+
+// TestHandleRequestDeleteAttachedByScheduledScaling covers the revisions of the scaling profiles,
+// which the services are moved to by scheduled scaling rather than CloudFormation.
+func TestHandleRequestDeleteAttachedByScheduledScaling(t *testing.T) {
+	cases := []struct {
+		name    string
+		setup   func(server *fakeapi.Server)
+		wantErr string
+	}{
+		{
+			name:  "succeeded",
+			setup: func(server *fakeapi.Server) { server.OperationDuration = 50 * time.Millisecond },
+		},
+		{
+			name:    "failed operation",
+			setup:   func(server *fakeapi.Server) { server.FailOperations = true },
+			wantErr: "OperationError status:FAILED",
+		},
+		{
+			name:    "stack not found",
+			setup:   func(server *fakeapi.Server) { server.FailRequest("DescribeStacks", "ValidationError") },
+			wantErr: "ValidationError",
+		},
+		{
+			name: "throttled delete",
+			setup: func(server *fakeapi.Server) {
+				server.FailRequest("DeleteAutoScalingConfiguration", "ThrottlingException")
+			},
+			wantErr: "ThrottlingException",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, server, serviceArns := newTestHandler(t)
+			ctx := context.Background()
+			oldProperties := newTestProperties("50", "5", "1")
+			properties := newTestProperties("100", "10", "2")
+
+			oldPhysicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", oldProperties, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			attach(server, serviceArns, oldPhysicalResourceID)
+			physicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestUpdate, oldPhysicalResourceID, properties, oldProperties))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			tc.setup(server)
+			deletedPhysicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, oldPhysicalResourceID, oldProperties, nil))
+			if deletedPhysicalResourceID != oldPhysicalResourceID {
+				t.Errorf("PhysicalResourceId = %s, want %s", deletedPhysicalResourceID, oldPhysicalResourceID)
+			}
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("err = %v, want %s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertServicesOn(t, server, serviceArns, physicalResourceID)
+			if configurations := activeConfigurations(server); len(configurations) != 1 || configurations[0].Arn != physicalResourceID {
+				t.Errorf("ACTIVE configurations = %+v, want %s", configurations, physicalResourceID)
+			}
+		})
+	}
+}
+
+// TestHandleRequestDeleteAttachedServices covers Delete on the stack deletion with services surviving it.
+func TestHandleRequestDeleteAttachedServices(t *testing.T) {
+	cases := []struct {
+		name string
+		// setup returns the ARN the retained service L1 is moved to, or an empty string if the revision is kept.
+		setup   func(server *fakeapi.Server, properties map[string]interface{}) string
+		wantErr string
+	}{
+		{
+			name: "retained service to DefaultConfiguration",
+			setup: func(server *fakeapi.Server, properties map[string]interface{}) string {
+				return server.DefaultAutoScalingConfigurationArn()
+			},
+		},
+		{
+			name: "retained service to the fallback",
+			setup: func(server *fakeapi.Server, properties map[string]interface{}) string {
+				fallbackArn := server.AddAutoScalingConfiguration("Shared", 100, 2, 1)
+				properties["FallbackAutoScalingConfigurationArn"] = fallbackArn
+				return fallbackArn
+			},
+		},
+		{
+			name: "RetainOnDelete",
+			setup: func(server *fakeapi.Server, properties map[string]interface{}) string {
+				properties["RetainOnDelete"] = "true"
+				return ""
+			},
+		},
+		{
+			name: "used outside the stack",
+			setup: func(server *fakeapi.Server, properties map[string]interface{}) string {
+				return ""
+			},
+			wantErr: "RetainOnDelete",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler, server, serviceArns := newTestHandler(t)
+			ctx := context.Background()
+			properties := newTestProperties("50", "5", "1")
+			destinationArn := tc.setup(server, properties)
+
+			physicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", properties, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			attach(server, serviceArns, physicalResourceID)
+			otherServiceArns := server.AddStack("OtherStack", "Web")
+			if tc.wantErr != "" {
+				attach(server, otherServiceArns, physicalResourceID)
+			}
+
+			// L2 is deleted and L1 is retained.
+			server.DeleteService(serviceArns[0])
+			_, _, err = handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, physicalResourceID, properties, nil))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("err = %v, want %s", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			configurations := activeConfigurations(server)
+			if destinationArn == "" {
+				if len(configurations) != 1 || countCalls(server, "AppRunner.DeleteAutoScalingConfiguration") != 0 {
+					t.Errorf("ACTIVE configurations = %+v, want the retained one", configurations)
+				}
+				assertServicesOn(t, server, serviceArns[1:], physicalResourceID)
+				return
+			}
+			if len(configurations) != 0 {
+				t.Errorf("ACTIVE configurations = %+v, want none", configurations)
+			}
+			assertServicesOn(t, server, serviceArns[1:], destinationArn)
+			if got := countCalls(server, "AppRunner.UpdateService"); got != 1 {
+				t.Errorf("UpdateService calls = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestHandleRequestLogging(t *testing.T) {
+	_, server, serviceArns := newTestHandler(t)
+	cfg := server.Config()
+	logging.AddAPICallLogging(&cfg)
+	handler := NewHandler(apprunner.NewFromConfig(cfg), cloudformation.NewFromConfig(cfg))
+	logs := &bytes.Buffer{}
+	handler.logger = logging.New(logs)
+
+	event := newTestEvent(cfn.RequestCreate, "", newTestProperties("50", "5", "1"), nil)
+	event.RequestID = "request-id"
+	event.StackID = "arn:aws:cloudformation:ap-northeast-1:123456789012:stack/AppRunnerGoStack/id"
+	physicalResourceID, _, err := handler.HandleRequest(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old revision is deleted with the services moved off it.
+	attach(server, serviceArns[:1], physicalResourceID)
+	server.DeleteService(serviceArns[1])
+	event.RequestType = cfn.RequestDelete
+	event.PhysicalResourceID = physicalResourceID
+	if _, _, err := handler.HandleRequest(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("not a JSON log: %s: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	messages := map[string]bool{}
+	for _, entry := range entries {
+		for key, want := range map[string]interface{}{
+			"RequestId":         "request-id",
+			"StackId":           event.StackID,
+			"LogicalResourceId": "AutoScalingConfiguration",
+		} {
+			if entry[key] != want {
+				t.Errorf("%s of %v = %v, want %v", key, entry["message"], entry[key], want)
+			}
+		}
+		if entry["level"] == "" || entry["time"] == "" {
+			t.Errorf("level or time is missing: %v", entry)
+		}
+		messages[entry["message"].(string)] = true
+
+		if entry["message"] == "AWS API call" && entry["Operation"] == "CreateAutoScalingConfiguration" {
+			if entry["Service"] != "AppRunner" || entry["DurationMs"] == nil {
+				t.Errorf("AWS API call log = %v", entry)
+			}
+		}
+		if entry["message"] == "operation status changed" && entry["OperationId"] == nil {
+			t.Errorf("OperationId is missing: %v", entry)
+		}
+	}
+	for _, message := range []string{
+		"request started",
+		"request succeeded",
+		"revision created",
+		"AWS API call",
+		"moving services off the revision",
+		"operation started",
+		"operation status changed",
+		"revision deleted",
+	} {
+		if !messages[message] {
+			t.Errorf("no log of %q", message)
+		}
+	}
+}
+
+func TestHandleRequestErrorPointsAtLogs(t *testing.T) {
+	handler, server, _ := newTestHandler(t)
+
+	logGroupName, logStreamName := lambdacontext.LogGroupName, lambdacontext.LogStreamName
+	lambdacontext.LogGroupName, lambdacontext.LogStreamName = "/aws/lambda/CustomResource", "2023/06/01/[$LATEST]stream"
+	t.Cleanup(func() { lambdacontext.LogGroupName, lambdacontext.LogStreamName = logGroupName, logStreamName })
+
+	server.FailRequest("CreateAutoScalingConfiguration", "ServiceQuotaExceededException")
+	event := newTestEvent(cfn.RequestCreate, "", newTestProperties("50", "5", "1"), nil)
+	event.RequestID = "request-id"
+	_, _, err := handler.HandleRequest(context.Background(), event)
+	if err == nil {
+		t.Fatal("no error")
+	}
+
+	want := "(CloudWatch Logs: /aws/lambda/CustomResource 2023/06/01/[$LATEST]stream, RequestId request-id)"
+	if !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("error = %q, want the suffix %q", err.Error(), want)
+	}
+}
+
+// emfMetrics returns the EMF objects written by the handler with the names of their metrics.
+func emfMetrics(t *testing.T, output string) []map[string]interface{} {
+	t.Helper()
+
+	objects := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		object := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(line), &object); err != nil {
+			t.Fatalf("not an EMF object: %s: %v", line, err)
+		}
+		if _, ok := object["_aws"]; !ok {
+			t.Fatalf("_aws is missing: %s", line)
+		}
+		objects = append(objects, object)
+	}
+	return objects
+}
+
+func TestHandleRequestMetrics(t *testing.T) {
+	handler, server, serviceArns := newTestHandler(t)
+	output := &bytes.Buffer{}
+	handler.metricsWriter = output
+	handler.metricsNamespace = "Test"
+
+	stackID := "arn:aws:cloudformation:ap-northeast-1:123456789012:stack/" + testStackName + "/id"
+	event := newTestEvent(cfn.RequestCreate, "", newTestProperties("50", "5", "1"), nil)
+	event.StackID = stackID
+	physicalResourceID, _, err := handler.HandleRequest(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Delete moves both the services, still on the revision, to DefaultConfiguration.
+	attach(server, serviceArns, physicalResourceID)
+	event = newTestEvent(cfn.RequestDelete, physicalResourceID, newTestProperties("50", "5", "1"), nil)
+	event.StackID = stackID
+	if _, _, err := handler.HandleRequest(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	server.FailRequest("CreateAutoScalingConfiguration", "ServiceQuotaExceededException")
+	event = newTestEvent(cfn.RequestCreate, "", newTestProperties("80", "5", "1"), nil)
+	event.StackID = stackID
+	if _, _, err := handler.HandleRequest(context.Background(), event); err == nil {
+		t.Fatal("no error")
+	}
+
+	summaries := []map[string]interface{}{}
+	waitTimes := 0
+	for _, object := range emfMetrics(t, output.String()) {
+		if object["StackName"] != testStackName {
+			t.Errorf("StackName = %v, want %s", object["StackName"], testStackName)
+		}
+		definition := object["_aws"].(map[string]interface{})["CloudWatchMetrics"].([]interface{})[0].(map[string]interface{})
+		if definition["Namespace"] != "Test" {
+			t.Errorf("Namespace = %v, want Test", definition["Namespace"])
+		}
+
+		if _, ok := object[metrics.OperationWaitTime]; ok {
+			waitTimes++
+			if object["RequestType"] != "Delete" || !strings.HasPrefix(object["ServiceName"].(string), testStackName+"-") {
+				t.Errorf("OperationWaitTime of %v", object)
+			}
+			continue
+		}
+		summaries = append(summaries, object)
+	}
+
+	if waitTimes != 2 {
+		t.Errorf("%d OperationWaitTime, want 2", waitTimes)
+	}
+	if len(summaries) != 3 {
+		t.Fatalf("%d metrics of the requests, want 3", len(summaries))
+	}
+	for i, want := range []struct {
+		requestType     string
+		servicesUpdated float64
+		failures        float64
+	}{
+		{"Create", 0, 0},
+		{"Delete", 2, 0},
+		{"Create", 0, 1},
+	} {
+		summary := summaries[i]
+		if summary["RequestType"] != want.requestType || summary[metrics.ServicesUpdated] != want.servicesUpdated || summary[metrics.Failures] != want.failures {
+			t.Errorf("metrics of request %d = %v, want %+v", i, summary, want)
+		}
+		if _, ok := summary[metrics.Retries]; !ok {
+			t.Errorf("Retries is missing: %v", summary)
+		}
+	}
+}
+
+func TestHandleRequestTags(t *testing.T) {
+	handler, server, _ := newTestHandler(t)
+
+	tagsOf := func(autoScalingConfigurationArn string) map[string]string {
+		for _, configuration := range server.AutoScalingConfigurations() {
+			if configuration.Arn == autoScalingConfigurationArn {
+				return configuration.Tags
+			}
+		}
+		t.Fatalf("%s not found", autoScalingConfigurationArn)
+		return nil
+	}
+	assertTags := func(autoScalingConfigurationArn string, want map[string]string) {
+		t.Helper()
+		got := tagsOf(autoScalingConfigurationArn)
+		if len(got) != len(want) {
+			t.Fatalf("tags = %v, want %v", got, want)
+		}
+		for key, value := range want {
+			if got[key] != value {
+				t.Errorf("tags = %v, want %v", got, want)
+			}
+		}
+	}
+	withTags := func(properties map[string]interface{}, tags map[string]interface{}) map[string]interface{} {
+		properties["Tags"] = tags
+		return properties
+	}
+
+	createProperties := withTags(newTestProperties("50", "5", "1"), map[string]interface{}{"CostCenter": "1234", "Team": "platform"})
+	physicalResourceID, _, err := handler.HandleRequest(context.Background(), newTestEvent(cfn.RequestCreate, "", createProperties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertTags(physicalResourceID, map[string]string{"CostCenter": "1234", "Team": "platform"})
+	if countCalls(server, "AppRunner.TagResource") != 0 {
+		t.Errorf("TagResource called for a created revision")
+	}
+
+	// A tag added outside of the stack is kept.
+	if err := apprunnerops.SyncTags(context.Background(), handler.apprunnerClient, physicalResourceID, map[string]string{"Owner": "someone"}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("tags changed", func(t *testing.T) {
+		updateProperties := withTags(newTestProperties("50", "5", "1"), map[string]interface{}{"Team": "apprunner", "Stage": "dev"})
+		gotID, _, err := handler.HandleRequest(context.Background(), newTestEvent(cfn.RequestUpdate, physicalResourceID, updateProperties, createProperties))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotID != physicalResourceID {
+			t.Errorf("physical ID = %s, want %s kept", gotID, physicalResourceID)
+		}
+		if countCalls(server, "AppRunner.CreateAutoScalingConfiguration") != 1 {
+			t.Errorf("a revision created for the tags")
+		}
+		assertTags(physicalResourceID, map[string]string{"Team": "apprunner", "Stage": "dev", "Owner": "someone"})
+		createProperties = updateProperties
+	})
+
+	t.Run("tags unchanged", func(t *testing.T) {
+		calls := countCalls(server, "AppRunner.TagResource") + countCalls(server, "AppRunner.UntagResource")
+		if _, _, err := handler.HandleRequest(context.Background(), newTestEvent(cfn.RequestUpdate, physicalResourceID, createProperties, createProperties)); err != nil {
+			t.Fatal(err)
+		}
+		if got := countCalls(server, "AppRunner.TagResource") + countCalls(server, "AppRunner.UntagResource"); got != calls {
+			t.Errorf("%d TagResource and UntagResource calls for unchanged tags", got-calls)
+		}
+	})
+
+	t.Run("values changed", func(t *testing.T) {
+		updateProperties := withTags(newTestProperties("80", "5", "1"), map[string]interface{}{"Team": "apprunner"})
+		gotID, _, err := handler.HandleRequest(context.Background(), newTestEvent(cfn.RequestUpdate, physicalResourceID, updateProperties, createProperties))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotID == physicalResourceID {
+			t.Fatal("no new revision for the changed values")
+		}
+		assertTags(gotID, map[string]string{"Team": "apprunner"})
+	})
+}
+
+// TestHandleRequestMigrationToNativeResource follows a deployment with AutoScalingConfigurationResource MIGRATING:
+// CloudFormation creates the native resources with the same names and values, moves the services to them
+// and then deletes the custom resources in the cleanup.
+func TestHandleRequestMigrationToNativeResource(t *testing.T) {
+	handler, server, serviceArns := newTestHandler(t)
+	ctx := context.Background()
+	properties := newTestProperties("50", "5", "1")
+	profileProperties := newTestProperties("100", "10", "2")
+	profileProperties["AutoScalingConfigurationName"] = testStackName + "-busy"
+
+	customArn, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", properties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attach(server, serviceArns, customArn)
+	// The profile's custom resource was created before the revision ARN became the physical ID.
+	customProfileArn, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", profileProperties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nativeTags := map[string]string{nativeResourceTagKey: nativeResourceTagValue}
+	nativeArn := server.AddAutoScalingConfiguration(testStackName, 50, 5, 1)
+	nativeProfileArn := server.AddAutoScalingConfiguration(testStackName+"-busy", 100, 10, 2)
+	for _, arn := range []string{nativeArn, nativeProfileArn} {
+		if err := apprunnerops.SyncTags(ctx, handler.apprunnerClient, arn, nativeTags, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	attach(server, serviceArns, nativeArn)
+	server.SetStackStatus(testStackName, "UPDATE_COMPLETE_CLEANUP_IN_PROGRESS")
+	calls := countCalls(server, "AppRunner.UpdateService")
+
+	if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, customArn, properties, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, legacyPhysicalResourceID, profileProperties, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := countCalls(server, "AppRunner.UpdateService") - calls; got != 0 {
+		t.Errorf("%d UpdateService calls by the cleanup, want none", got)
+	}
+	assertServicesOn(t, server, serviceArns, nativeArn)
+
+	active := map[string]bool{}
+	for _, configuration := range server.AutoScalingConfigurations() {
+		active[configuration.Arn] = configuration.Status == "ACTIVE"
+	}
+	for arn, want := range map[string]bool{customArn: false, customProfileArn: false, nativeArn: true, nativeProfileArn: true} {
+		if active[arn] != want {
+			t.Errorf("ACTIVE of %s = %v, want %v", arn, active[arn], want)
+		}
+	}
+}
+
+// TestHandleRequestAccountDefault follows a resource with AccountDefault through its updates and deletion:
+// its revision is the account default while AccountDefault is set, and the default is restored when it is turned off or deleted.
+func TestHandleRequestAccountDefault(t *testing.T) {
+	handler, server, serviceArns := newTestHandler(t)
+	ctx := context.Background()
+	defaultConfigurationArn := server.DefaultAutoScalingConfigurationArn()
+	properties := newTestProperties("50", "5", "1")
+	properties["AccountDefault"] = "true"
+
+	physicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", properties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := server.AccountDefaultAutoScalingConfigurationArn(); got != physicalResourceID {
+		t.Fatalf("account default = %s, want %s", got, physicalResourceID)
+	}
+	attach(server, serviceArns, physicalResourceID)
+
+	t.Run("values changed", func(t *testing.T) {
+		updateProperties := newTestProperties("80", "5", "1")
+		updateProperties["AccountDefault"] = "true"
+		gotID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestUpdate, physicalResourceID, updateProperties, properties))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := server.AccountDefaultAutoScalingConfigurationArn(); got != gotID {
+			t.Fatalf("account default = %s, want the new revision %s", got, gotID)
+		}
+		attach(server, serviceArns, gotID)
+
+		// The old revision is no longer the default, so Delete of the cleanup deletes it.
+		if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, physicalResourceID, properties, nil)); err != nil {
+			t.Fatal(err)
+		}
+		if got := server.AccountDefaultAutoScalingConfigurationArn(); got != gotID {
+			t.Errorf("account default = %s after the cleanup, want %s", got, gotID)
+		}
+		physicalResourceID = gotID
+		properties = updateProperties
+	})
+
+	t.Run("account default turned off", func(t *testing.T) {
+		updateProperties := newTestProperties("80", "5", "1")
+		if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestUpdate, physicalResourceID, updateProperties, properties)); err != nil {
+			t.Fatal(err)
+		}
+		if got := server.AccountDefaultAutoScalingConfigurationArn(); got != defaultConfigurationArn {
+			t.Errorf("account default = %s, want DefaultConfiguration %s restored", got, defaultConfigurationArn)
+		}
+
+		if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestUpdate, physicalResourceID, properties, updateProperties)); err != nil {
+			t.Fatal(err)
+		}
+		if got := server.AccountDefaultAutoScalingConfigurationArn(); got != physicalResourceID {
+			t.Fatalf("account default = %s, want %s again", got, physicalResourceID)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		for _, serviceArn := range serviceArns {
+			server.DeleteService(serviceArn)
+		}
+		if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, physicalResourceID, properties, nil)); err != nil {
+			t.Fatal(err)
+		}
+		if got := server.AccountDefaultAutoScalingConfigurationArn(); got != defaultConfigurationArn {
+			t.Errorf("account default = %s, want DefaultConfiguration %s restored", got, defaultConfigurationArn)
+		}
+		if configurations := activeConfigurations(server); len(configurations) != 0 {
+			t.Errorf("%d revisions left, want all deleted", len(configurations))
+		}
+	})
+}
+
+// TestHandleRequestDeleteToAccountDefault moves the services of a deleted revision to the account default,
+// which is another AutoScalingConfiguration than DefaultConfiguration.
+func TestHandleRequestDeleteToAccountDefault(t *testing.T) {
+	handler, server, serviceArns := newTestHandler(t)
+	ctx := context.Background()
+	sharedArn := server.AddAutoScalingConfiguration("shared", 100, 10, 1)
+	server.SetAccountDefault(sharedArn)
+	properties := newTestProperties("50", "5", "1")
+
+	physicalResourceID, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestCreate, "", properties, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	attach(server, serviceArns, physicalResourceID)
+
+	if _, _, err := handler.HandleRequest(ctx, newTestEvent(cfn.RequestDelete, physicalResourceID, properties, nil)); err != nil {
+		t.Fatal(err)
+	}
+	assertServicesOn(t, server, serviceArns, sharedArn)
+	if got := server.AccountDefaultAutoScalingConfigurationArn(); got != sharedArn {
+		t.Errorf("account default = %s, want %s left as is", got, sharedArn)
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go-cdk-go-managed-apprunner/custom/apprunnerops"
+	"go-cdk-go-managed-apprunner/custom/logging"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// GarbageCollectionEvent is the input of the EventBridge Scheduler schedule.
+// Allowlist has the path.Match patterns of the names or the ARNs of the AutoScalingConfigurations never deleted.
+// IncludeUntagged also collects the revisions without the stack name tag, whose stacks are inferred from their names.
+type GarbageCollectionEvent struct {
+	DryRun          bool
+	Allowlist       []string
+	IncludeUntagged bool
+}
+
+type Summary struct {
+	DryRun  bool
+	Orphans []*apprunnerops.OrphanedAutoScalingConfiguration
+}
+
+func HandleRequest(ctx context.Context, event GarbageCollectionEvent) (*Summary, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(os.Getenv("AWS_REGION")))
+	if err != nil {
+		return nil, err
+	}
+	logging.AddAPICallLogging(&cfg)
+
+	return run(logging.NewContext(ctx, logging.New(os.Stdout)), cfg, event)
+}
+
+// run finds the orphaned AutoScalingConfigurations of the account, and deletes them unless DryRun.
+func run(ctx context.Context, cfg aws.Config, event GarbageCollectionEvent) (*Summary, error) {
+	logger := logging.FromContext(ctx).With("DryRun", event.DryRun)
+	apprunnerClient := apprunner.NewFromConfig(cfg)
+
+	orphans, err := apprunnerops.FindOrphanedAutoScalingConfigurations(
+		ctx,
+		apprunnerClient,
+		cloudformation.NewFromConfig(cfg),
+		event.Allowlist,
+		event.IncludeUntagged,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &Summary{
+		DryRun:  event.DryRun,
+		Orphans: orphans,
+	}
+	if event.DryRun {
+		for _, orphan := range orphans {
+			logger.Info("orphaned revision found", "AutoScalingConfigurationArn", orphan.AutoScalingConfigurationArn)
+		}
+		return summary, nil
+	}
+
+	err = apprunnerops.DeleteOrphanedAutoScalingConfigurations(ctx, apprunnerClient, orphans)
+	return summary, err
+}
+
+// The same binary works as a CLI command outside of Lambda, which only reports the orphans without -delete:
+//
+//	go run ./custom/autoscalinggc [-delete] [-allow 'shared-*,arn:aws:apprunner:*'] [-include-untagged] [-region ap-northeast-1] [-profile profile]
+func main() {
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		lambda.Start(HandleRequest)
+		return
+	}
+
+	deleteOrphans := flag.Bool("delete", false, "delete the orphaned AutoScalingConfigurations instead of only reporting them")
+	allow := flag.String("allow", "", "comma-separated patterns of the names or the ARNs of the AutoScalingConfigurations never deleted")
+	includeUntagged := flag.Bool("include-untagged", false, "also collect the AutoScalingConfigurations without the stack name tag by their names")
+	region := flag.String("region", "", "AWS region (default: resolved from the environment or the profile)")
+	profile := flag.String("profile", "", "AWS profile")
+	flag.Parse()
+
+	allowlist := []string{}
+	for _, pattern := range strings.Split(*allow, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			allowlist = append(allowlist, pattern)
+		}
+	}
+
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(*region), config.WithSharedConfigProfile(*profile))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	summary, err := run(ctx, cfg, GarbageCollectionEvent{
+		DryRun:          !*deleteOrphans,
+		Allowlist:       allowlist,
+		IncludeUntagged: *includeUntagged,
+	})
+	if summary != nil {
+		for _, orphan := range summary.Orphans {
+			switch {
+			case orphan.Error != "":
+				fmt.Printf("%s: FAILED (%s)\n", orphan.AutoScalingConfigurationArn, orphan.Error)
+			case orphan.Deleted:
+				fmt.Printf("%s: deleted\n", orphan.AutoScalingConfigurationArn)
+			default:
+				fmt.Printf("%s: orphaned\n", orphan.AutoScalingConfigurationArn)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
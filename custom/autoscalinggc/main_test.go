@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"go-cdk-go-managed-apprunner/custom/apprunnerops"
+	"go-cdk-go-managed-apprunner/custom/fakeapi"
+	"sort"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+)
+
+type testAccount struct {
+	server  *fakeapi.Server
+	orphans []string
+	// untagged are the orphans by their names, which are collected only with IncludeUntagged.
+	untagged []string
+	kept     []string
+}
+
+// newTestAccount makes an account with a revision of every kind, listed in the pages of 2.
+func newTestAccount(t *testing.T) *testAccount {
+	t.Helper()
+
+	server := fakeapi.NewServer()
+	t.Cleanup(server.Close)
+	server.PageSize = 2
+	account := &testAccount{server: server}
+	apprunnerClient := apprunner.NewFromConfig(server.Config())
+	tag := func(arn string, key string, stackName string) string {
+		t.Helper()
+		if err := apprunnerops.SyncTags(context.Background(), apprunnerClient, arn, map[string]string{key: stackName}, nil); err != nil {
+			t.Fatal(err)
+		}
+		return arn
+	}
+	stackTag := func(arn string, stackName string) string {
+		return tag(arn, "AppRunnerStackName", stackName)
+	}
+
+	// The stack's revision used by the services, and a scaling profile without services.
+	serviceArns := server.AddStack("AppRunnerGoStack", "L2", "L1")
+	stackArn := stackTag(server.AddAutoScalingConfiguration("AppRunnerGoStack", 50, 5, 1), "AppRunnerGoStack")
+	for _, serviceArn := range serviceArns {
+		server.SetServiceAutoScalingConfiguration(serviceArn, stackArn)
+	}
+	account.kept = append(account.kept, stackArn, stackTag(server.AddAutoScalingConfiguration("AppRunnerGoStack-business", 50, 3, 2), "AppRunnerGoStack"))
+
+	// An old revision of the live stack is left to its custom resource.
+	account.kept = append(account.kept, stackTag(server.AddAutoScalingConfiguration("AppRunnerGoStack", 80, 5, 1), "AppRunnerGoStack"))
+
+	// The stack was deleted with RetainOnDelete, and one of its revisions is still used by a retained service.
+	retainedArns := server.AddStack("Retained", "L1")
+	usedArn := stackTag(server.AddAutoScalingConfiguration("Retained", 50, 5, 1), "Retained")
+	server.SetServiceAutoScalingConfiguration(retainedArns[0], usedArn)
+	server.SetStackStatus("Retained", "DELETE_COMPLETE")
+	account.kept = append(account.kept, usedArn)
+	account.orphans = append(account.orphans, stackTag(server.AddAutoScalingConfiguration("Retained-nights", 50, 3, 1), "Retained"))
+
+	// The creation of the stack failed and was rolled back, leaving the revisions of the custom and the native resources.
+	server.AddStack("Failed")
+	server.SetStackStatus("Failed", "ROLLBACK_COMPLETE")
+	account.orphans = append(account.orphans,
+		stackTag(server.AddAutoScalingConfiguration("Failed", 50, 5, 1), "Failed"),
+		tag(server.AddAutoScalingConfiguration("Failed-business", 50, 3, 2), "aws:cloudformation:stack-name", "Failed"),
+	)
+
+	// Deleted with its stack gone, in two revisions: the older one is not the latest of the name.
+	account.orphans = append(account.orphans,
+		stackTag(server.AddAutoScalingConfiguration("Gone", 50, 5, 1), "Gone"),
+		stackTag(server.AddAutoScalingConfiguration("Gone", 80, 5, 1), "Gone"),
+	)
+
+	// Created outside of the stacks, whose names alone tell nothing of their owners:
+	// one is named like a revision of the deleted stack, and the others are of a name without any stack.
+	account.untagged = append(account.untagged,
+		server.AddAutoScalingConfiguration("Retained-other-team", 50, 5, 1),
+		server.AddAutoScalingConfiguration("leaked_config", 50, 5, 1),
+		server.AddAutoScalingConfiguration("leaked_config", 80, 5, 1),
+	)
+
+	// Allowlisted, and the account default, which is not the latest revision of its name.
+	account.kept = append(account.kept, server.AddAutoScalingConfiguration("shared-api", 100, 10, 1))
+	accountDefaultArn := server.AddAutoScalingConfiguration("Platform", 100, 10, 1)
+	server.SetAccountDefault(accountDefaultArn)
+	account.kept = append(account.kept, accountDefaultArn, server.DefaultAutoScalingConfigurationArn())
+	account.untagged = append(account.untagged, server.AddAutoScalingConfiguration("Platform", 100, 20, 1))
+
+	return account
+}
+
+func (a *testAccount) active() map[string]bool {
+	active := map[string]bool{}
+	for _, configuration := range a.server.AutoScalingConfigurations() {
+		active[configuration.Arn] = configuration.Status == "ACTIVE"
+	}
+	return active
+}
+
+func orphanArns(summary *Summary) []string {
+	arns := []string{}
+	for _, orphan := range summary.Orphans {
+		arns = append(arns, orphan.AutoScalingConfigurationArn)
+	}
+	sort.Strings(arns)
+	return arns
+}
+
+func assertOrphans(t *testing.T, summary *Summary, want []string) {
+	t.Helper()
+
+	want = append([]string{}, want...)
+	sort.Strings(want)
+	got := orphanArns(summary)
+	if len(got) != len(want) {
+		t.Fatalf("orphans = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("orphans = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRunDryRun(t *testing.T) {
+	account := newTestAccount(t)
+
+	summary, err := run(context.Background(), account.server.Config(), GarbageCollectionEvent{
+		DryRun:    true,
+		Allowlist: []string{"shared-*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertOrphans(t, summary, account.orphans)
+	active := account.active()
+	for _, orphan := range summary.Orphans {
+		if orphan.Deleted || !active[orphan.AutoScalingConfigurationArn] {
+			t.Errorf("%s deleted in the dry run", orphan.AutoScalingConfigurationArn)
+		}
+	}
+}
+
+func TestRunDelete(t *testing.T) {
+	account := newTestAccount(t)
+
+	summary, err := run(context.Background(), account.server.Config(), GarbageCollectionEvent{
+		Allowlist: []string{"shared-*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertOrphans(t, summary, account.orphans)
+	active := account.active()
+	for _, orphan := range summary.Orphans {
+		if !orphan.Deleted || active[orphan.AutoScalingConfigurationArn] {
+			t.Errorf("%s not deleted", orphan.AutoScalingConfigurationArn)
+		}
+	}
+	for _, arn := range append(account.kept, account.untagged...) {
+		if !active[arn] {
+			t.Errorf("%s deleted, want kept", arn)
+		}
+	}
+}
+
+func TestRunIncludeUntagged(t *testing.T) {
+	account := newTestAccount(t)
+
+	summary, err := run(context.Background(), account.server.Config(), GarbageCollectionEvent{
+		Allowlist:       []string{"shared-*"},
+		IncludeUntagged: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertOrphans(t, summary, append(account.orphans, account.untagged...))
+	active := account.active()
+	for _, arn := range account.kept {
+		if !active[arn] {
+			t.Errorf("%s deleted, want kept", arn)
+		}
+	}
+}
+
+func TestRunAllowlistByArn(t *testing.T) {
+	account := newTestAccount(t)
+
+	summary, err := run(context.Background(), account.server.Config(), GarbageCollectionEvent{
+		DryRun:    true,
+		Allowlist: []string{"shared-*", account.orphans[0]},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertOrphans(t, summary, account.orphans[1:])
+
+	if _, err := run(context.Background(), account.server.Config(), GarbageCollectionEvent{Allowlist: []string{"[shared"}}); err == nil {
+		t.Error("no error for an invalid allowlist pattern")
+	}
+}
+
+func TestRunDeleteFailure(t *testing.T) {
+	account := newTestAccount(t)
+	account.server.FailRequest("DeleteAutoScalingConfiguration", "InvalidRequestException")
+
+	summary, err := run(context.Background(), account.server.Config(), GarbageCollectionEvent{
+		Allowlist: []string{"shared-*"},
+	})
+	if err == nil {
+		t.Fatal("no error for the failed deletions")
+	}
+
+	assertOrphans(t, summary, account.orphans)
+	for _, orphan := range summary.Orphans {
+		if orphan.Deleted || orphan.Error == "" {
+			t.Errorf("%s: Deleted = %v, Error = %q, want the error", orphan.AutoScalingConfigurationArn, orphan.Deleted, orphan.Error)
+		}
+	}
+}
@@ -0,0 +1,480 @@
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type appRunnerRequest struct {
+	AutoScalingConfigurationName string
+	AutoScalingConfigurationArn  string
+	LatestOnly                   *bool
+	MaxConcurrency               int32
+	MaxSize                      int32
+	MinSize                      int32
+	ServiceArn                   string
+	ResourceArn                  string
+	Tags                         []appRunnerTag
+	TagKeys                      []string
+	MaxResults                   int
+	NextToken                    string
+}
+
+type appRunnerTag struct {
+	Key   string
+	Value string
+}
+
+type appRunnerError struct {
+	code    string
+	message string
+}
+
+func (s *Server) serveAppRunner(w http.ResponseWriter, r *http.Request, action string) {
+	request := &appRunnerRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		writeAppRunnerError(w, &appRunnerError{code: "InvalidRequestException", message: err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if code := s.failure("AppRunner", action); code != "" {
+		writeAppRunnerError(w, &appRunnerError{code: code, message: "injected failure of " + action})
+		return
+	}
+
+	now := time.Now()
+	s.settleOperations(now)
+
+	var output map[string]interface{}
+	var appErr *appRunnerError
+	switch action {
+	case "ListAutoScalingConfigurations":
+		output, appErr = s.listAutoScalingConfigurations(request)
+	case "CreateAutoScalingConfiguration":
+		output, appErr = s.createAutoScalingConfigurationAction(request)
+	case "DescribeAutoScalingConfiguration":
+		output, appErr = s.describeAutoScalingConfiguration(request)
+	case "DeleteAutoScalingConfiguration":
+		output, appErr = s.deleteAutoScalingConfiguration(request)
+	case "DescribeService":
+		output, appErr = s.describeService(request, now)
+	case "UpdateService":
+		output, appErr = s.updateService(request, now)
+	case "PauseService":
+		output, appErr = s.pauseOrResumeService(request, now, "PAUSE_SERVICE", "RUNNING", "PAUSED")
+	case "ResumeService":
+		output, appErr = s.pauseOrResumeService(request, now, "RESUME_SERVICE", "PAUSED", "RUNNING")
+	case "ListOperations":
+		output, appErr = s.listOperations(request, now)
+	case "TagResource":
+		output, appErr = s.tagResource(request)
+	case "UntagResource":
+		output, appErr = s.untagResource(request)
+	case "ListTagsForResource":
+		output, appErr = s.listTagsForResource(request)
+	case "ListServicesForAutoScalingConfiguration":
+		output, appErr = s.listServicesForAutoScalingConfiguration(request)
+	case "UpdateDefaultAutoScalingConfiguration":
+		output, appErr = s.updateDefaultAutoScalingConfiguration(request)
+	default:
+		appErr = &appRunnerError{code: "UnknownOperationException", message: "unsupported action " + action}
+	}
+
+	if appErr != nil {
+		writeAppRunnerError(w, appErr)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	json.NewEncoder(w).Encode(output)
+}
+
+func writeAppRunnerError(w http.ResponseWriter, appErr *appRunnerError) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.Header().Set("X-Amzn-ErrorType", appErr.code)
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]string{
+		"__type":  appErr.code,
+		"Message": appErr.message,
+	})
+}
+
+func epochSeconds(t time.Time) float64 {
+	return float64(t.UnixNano()) / float64(time.Second)
+}
+
+func autoScalingConfigurationOutput(configuration *AutoScalingConfiguration) map[string]interface{} {
+	output := map[string]interface{}{
+		"AutoScalingConfigurationArn":      configuration.Arn,
+		"AutoScalingConfigurationName":     configuration.Name,
+		"AutoScalingConfigurationRevision": configuration.Revision,
+		"Latest":                           configuration.Latest,
+		"Status":                           configuration.Status,
+		"MaxConcurrency":                   configuration.MaxConcurrency,
+		"MaxSize":                          configuration.MaxSize,
+		"MinSize":                          configuration.MinSize,
+		"CreatedAt":                        epochSeconds(configuration.CreatedAt),
+		"IsDefault":                        configuration.IsDefault,
+	}
+	if !configuration.DeletedAt.IsZero() {
+		output["DeletedAt"] = epochSeconds(configuration.DeletedAt)
+	}
+	return output
+}
+
+func autoScalingConfigurationSummary(configuration *AutoScalingConfiguration) map[string]interface{} {
+	return map[string]interface{}{
+		"AutoScalingConfigurationArn":      configuration.Arn,
+		"AutoScalingConfigurationName":     configuration.Name,
+		"AutoScalingConfigurationRevision": configuration.Revision,
+		"Status":                           configuration.Status,
+		"CreatedAt":                        epochSeconds(configuration.CreatedAt),
+		"IsDefault":                        configuration.IsDefault,
+	}
+}
+
+// It must be called with the lock held.
+func (s *Server) findAutoScalingConfiguration(arn string) (*AutoScalingConfiguration, *appRunnerError) {
+	for _, configuration := range s.configurations {
+		if configuration.Arn == arn {
+			return configuration, nil
+		}
+	}
+	return nil, &appRunnerError{code: "ResourceNotFoundException", message: "AutoScalingConfiguration not found: " + arn}
+}
+
+// It must be called with the lock held.
+func (s *Server) findService(arn string) (*Service, *appRunnerError) {
+	service, ok := s.services[arn]
+	if !ok {
+		return nil, &appRunnerError{code: "ResourceNotFoundException", message: "Service not found: " + arn}
+	}
+	return service, nil
+}
+
+// The revisions are listed from the newest, in the pages of MaxResults or PageSize.
+// Like the real API, only the latest revision of each name is listed unless LatestOnly is false.
+func (s *Server) listAutoScalingConfigurations(request *appRunnerRequest) (map[string]interface{}, *appRunnerError) {
+	latestOnly := request.LatestOnly == nil || *request.LatestOnly
+	summaries := []interface{}{}
+	for i := len(s.configurations) - 1; i >= 0; i-- {
+		configuration := s.configurations[i]
+		if configuration.Status != "ACTIVE" {
+			continue
+		}
+		if request.AutoScalingConfigurationName != "" && configuration.Name != request.AutoScalingConfigurationName {
+			continue
+		}
+		if latestOnly && !configuration.Latest {
+			continue
+		}
+		summaries = append(summaries, autoScalingConfigurationSummary(configuration))
+	}
+
+	return s.page(request, "AutoScalingConfigurationSummaryList", summaries)
+}
+
+// The services are listed in the order of the ARNs, without the deleted ones.
+func (s *Server) listServicesForAutoScalingConfiguration(request *appRunnerRequest) (map[string]interface{}, *appRunnerError) {
+	if _, appErr := s.findAutoScalingConfiguration(request.AutoScalingConfigurationArn); appErr != nil {
+		return nil, appErr
+	}
+
+	serviceArns := []interface{}{}
+	for _, serviceArn := range s.sortedServiceArns() {
+		service := s.services[serviceArn]
+		if service.Status != "DELETED" && service.AutoScalingConfigurationArn == request.AutoScalingConfigurationArn {
+			serviceArns = append(serviceArns, serviceArn)
+		}
+	}
+
+	return s.page(request, "ServiceArnList", serviceArns)
+}
+
+// page returns the page of the items from NextToken, the index of the first item.
+// It must be called with the lock held.
+func (s *Server) page(request *appRunnerRequest, key string, items []interface{}) (map[string]interface{}, *appRunnerError) {
+	start := 0
+	if request.NextToken != "" {
+		var err error
+		start, err = strconv.Atoi(request.NextToken)
+		if err != nil || start < 0 || start > len(items) {
+			return nil, &appRunnerError{code: "InvalidRequestException", message: "invalid NextToken: " + request.NextToken}
+		}
+	}
+	pageSize := request.MaxResults
+	if s.PageSize > 0 && (pageSize == 0 || s.PageSize < pageSize) {
+		pageSize = s.PageSize
+	}
+
+	end := len(items)
+	if pageSize > 0 && start+pageSize < end {
+		end = start + pageSize
+	}
+	output := map[string]interface{}{
+		key: items[start:end],
+	}
+	if end < len(items) {
+		output["NextToken"] = strconv.Itoa(end)
+	}
+	return output, nil
+}
+
+func (s *Server) createAutoScalingConfigurationAction(request *appRunnerRequest) (map[string]interface{}, *appRunnerError) {
+	name := request.AutoScalingConfigurationName
+	if len(name) < 4 || len(name) > 32 {
+		return nil, &appRunnerError{code: "InvalidRequestException", message: "AutoScalingConfigurationName must be 4 to 32 characters: " + name}
+	}
+	if name == "DefaultConfiguration" {
+		return nil, &appRunnerError{code: "InvalidRequestException", message: "DefaultConfiguration is reserved"}
+	}
+
+	configuration := s.createAutoScalingConfiguration(name, request.MaxConcurrency, request.MaxSize, request.MinSize)
+	for _, tag := range request.Tags {
+		configuration.Tags[tag.Key] = tag.Value
+	}
+	return map[string]interface{}{
+		"AutoScalingConfiguration": autoScalingConfigurationOutput(configuration),
+	}, nil
+}
+
+func (s *Server) describeAutoScalingConfiguration(request *appRunnerRequest) (map[string]interface{}, *appRunnerError) {
+	configuration, appErr := s.findAutoScalingConfiguration(request.AutoScalingConfigurationArn)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return map[string]interface{}{
+		"AutoScalingConfiguration": autoScalingConfigurationOutput(configuration),
+	}, nil
+}
+
+// Only the revisions of AutoScalingConfigurations can be tagged in the fake.
+func (s *Server) tagResource(request *appRunnerRequest) (map[string]interface{}, *appRunnerError) {
+	configuration, appErr := s.findAutoScalingConfiguration(request.ResourceArn)
+	if appErr != nil {
+		return nil, appErr
+	}
+	for _, tag := range request.Tags {
+		configuration.Tags[tag.Key] = tag.Value
+	}
+	return map[string]interface{}{}, nil
+}
+
+func (s *Server) untagResource(request *appRunnerRequest) (map[string]interface{}, *appRunnerError) {
+	configuration, appErr := s.findAutoScalingConfiguration(request.ResourceArn)
+	if appErr != nil {
+		return nil, appErr
+	}
+	for _, key := range request.TagKeys {
+		delete(configuration.Tags, key)
+	}
+	return map[string]interface{}{}, nil
+}
+
+func (s *Server) listTagsForResource(request *appRunnerRequest) (map[string]interface{}, *appRunnerError) {
+	configuration, appErr := s.findAutoScalingConfiguration(request.ResourceArn)
+	if appErr != nil {
+		return nil, appErr
+	}
+	tags := []appRunnerTag{}
+	for key, value := range configuration.Tags {
+		tags = append(tags, appRunnerTag{Key: key, Value: value})
+	}
+	return map[string]interface{}{
+		"Tags": tags,
+	}, nil
+}
+
+// The account default moves to the revision, and IsDefault of the others becomes false.
+func (s *Server) updateDefaultAutoScalingConfiguration(request *appRunnerRequest) (map[string]interface{}, *appRunnerError) {
+	configuration, appErr := s.findAutoScalingConfiguration(request.AutoScalingConfigurationArn)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if configuration.Status != "ACTIVE" {
+		return nil, &appRunnerError{code: "InvalidRequestException", message: "AutoScalingConfiguration is deleted: " + configuration.Arn}
+	}
+	for _, other := range s.configurations {
+		other.IsDefault = other == configuration
+	}
+	return map[string]interface{}{
+		"AutoScalingConfiguration": autoScalingConfigurationOutput(configuration),
+	}, nil
+}
+
+// A revision in use by a service, DefaultConfiguration or the account default cannot be deleted, like the real API.
+func (s *Server) deleteAutoScalingConfiguration(request *appRunnerRequest) (map[string]interface{}, *appRunnerError) {
+	configuration, appErr := s.findAutoScalingConfiguration(request.AutoScalingConfigurationArn)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if configuration.Status != "ACTIVE" {
+		return nil, &appRunnerError{code: "ResourceNotFoundException", message: "AutoScalingConfiguration already deleted: " + configuration.Arn}
+	}
+	if configuration.Name == "DefaultConfiguration" {
+		return nil, &appRunnerError{code: "InvalidRequestException", message: "DefaultConfiguration cannot be deleted"}
+	}
+	if configuration.IsDefault {
+		return nil, &appRunnerError{code: "InvalidRequestException", message: "the account default AutoScalingConfiguration cannot be deleted: " + configuration.Arn}
+	}
+	for _, serviceArn := range s.sortedServiceArns() {
+		if s.services[serviceArn].Status != "DELETED" && s.services[serviceArn].AutoScalingConfigurationArn == configuration.Arn {
+			return nil, &appRunnerError{
+				code:    "InvalidRequestException",
+				message: fmt.Sprintf("AutoScalingConfiguration %s is in use by %s", configuration.Arn, serviceArn),
+			}
+		}
+	}
+
+	configuration.Status = "INACTIVE"
+	configuration.DeletedAt = time.Now()
+	if configuration.Latest {
+		configuration.Latest = false
+		for i := len(s.configurations) - 1; i >= 0; i-- {
+			if s.configurations[i].Name == configuration.Name && s.configurations[i].Status == "ACTIVE" {
+				s.configurations[i].Latest = true
+				break
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"AutoScalingConfiguration": autoScalingConfigurationOutput(configuration),
+	}, nil
+}
+
+// It must be called with the lock held.
+func (s *Server) serviceOutput(service *Service, now time.Time) map[string]interface{} {
+	status := service.Status
+	if s.inProgress(service.Arn, now) {
+		status = "OPERATION_IN_PROGRESS"
+	}
+
+	output := map[string]interface{}{
+		"ServiceArn":  service.Arn,
+		"ServiceName": service.Name,
+		"ServiceId":   service.ID,
+		"Status":      status,
+	}
+	if configuration, appErr := s.findAutoScalingConfiguration(service.AutoScalingConfigurationArn); appErr == nil {
+		output["AutoScalingConfigurationSummary"] = autoScalingConfigurationSummary(configuration)
+	}
+	return output
+}
+
+func (s *Server) describeService(request *appRunnerRequest, now time.Time) (map[string]interface{}, *appRunnerError) {
+	service, appErr := s.findService(request.ServiceArn)
+	if appErr != nil {
+		return nil, appErr
+	}
+	return map[string]interface{}{
+		"Service": s.serviceOutput(service, now),
+	}, nil
+}
+
+// The AutoScalingConfiguration is applied to the service when the operation succeeds after OperationDuration.
+func (s *Server) updateService(request *appRunnerRequest, now time.Time) (map[string]interface{}, *appRunnerError) {
+	service, appErr := s.findService(request.ServiceArn)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if s.inProgress(service.Arn, now) {
+		return nil, &appRunnerError{code: "InvalidStateException", message: "an operation is in progress on " + service.Arn}
+	}
+	if service.Status == "DELETED" {
+		return nil, &appRunnerError{code: "InvalidStateException", message: "the service is deleted: " + service.Arn}
+	}
+	if request.AutoScalingConfigurationArn != "" {
+		configuration, appErr := s.findAutoScalingConfiguration(request.AutoScalingConfigurationArn)
+		if appErr != nil {
+			return nil, appErr
+		}
+		if configuration.Status != "ACTIVE" {
+			return nil, &appRunnerError{code: "InvalidRequestException", message: "AutoScalingConfiguration is deleted: " + configuration.Arn}
+		}
+	}
+
+	op := &operation{
+		id:                          strings.ToLower(s.newID()),
+		operationType:               "UPDATE_SERVICE",
+		serviceArn:                  service.Arn,
+		startedAt:                   now,
+		endsAt:                      now.Add(s.OperationDuration),
+		failed:                      s.FailOperations,
+		autoScalingConfigurationArn: request.AutoScalingConfigurationArn,
+	}
+	s.operations[service.Arn] = append(s.operations[service.Arn], op)
+	s.settleOperations(now)
+
+	return map[string]interface{}{
+		"Service":     s.serviceOutput(service, now),
+		"OperationId": op.id,
+	}, nil
+}
+
+// The service moves from the status to the next one when the operation succeeds after OperationDuration.
+// Like the real API, a service not in the status, e.g. pausing a PAUSED service, is rejected.
+func (s *Server) pauseOrResumeService(request *appRunnerRequest, now time.Time, operationType string, status string, nextStatus string) (map[string]interface{}, *appRunnerError) {
+	service, appErr := s.findService(request.ServiceArn)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if s.inProgress(service.Arn, now) {
+		return nil, &appRunnerError{code: "InvalidStateException", message: "an operation is in progress on " + service.Arn}
+	}
+	if service.Status != status {
+		return nil, &appRunnerError{code: "InvalidStateException", message: fmt.Sprintf("the service is %s, not %s: %s", service.Status, status, service.Arn)}
+	}
+
+	op := &operation{
+		id:            strings.ToLower(s.newID()),
+		operationType: operationType,
+		serviceArn:    service.Arn,
+		startedAt:     now,
+		endsAt:        now.Add(s.OperationDuration),
+		failed:        s.FailOperations,
+		serviceStatus: nextStatus,
+	}
+	s.operations[service.Arn] = append(s.operations[service.Arn], op)
+	s.settleOperations(now)
+
+	return map[string]interface{}{
+		"Service":     s.serviceOutput(service, now),
+		"OperationId": op.id,
+	}, nil
+}
+
+// The operations are listed from the newest.
+func (s *Server) listOperations(request *appRunnerRequest, now time.Time) (map[string]interface{}, *appRunnerError) {
+	if _, appErr := s.findService(request.ServiceArn); appErr != nil {
+		return nil, appErr
+	}
+
+	summaries := []interface{}{}
+	operations := s.operations[request.ServiceArn]
+	for i := len(operations) - 1; i >= 0; i-- {
+		op := operations[i]
+		summary := map[string]interface{}{
+			"Id":        op.id,
+			"Type":      op.operationType,
+			"Status":    op.status(now),
+			"TargetArn": op.serviceArn,
+			"StartedAt": epochSeconds(op.startedAt),
+			"UpdatedAt": epochSeconds(now),
+		}
+		if op.status(now) != "IN_PROGRESS" {
+			summary["EndedAt"] = epochSeconds(op.endsAt)
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return map[string]interface{}{
+		"OperationSummaryList": summaries,
+	}, nil
+}
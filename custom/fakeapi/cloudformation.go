@@ -0,0 +1,102 @@
+package fakeapi
+
+import (
+	"encoding/xml"
+	"net/http"
+)
+
+const cloudFormationNamespace = "http://cloudformation.amazonaws.com/doc/2010-05-15/"
+
+type describeStacksResponse struct {
+	XMLName xml.Name             `xml:"DescribeStacksResponse"`
+	Xmlns   string               `xml:"xmlns,attr"`
+	Result  describeStacksResult `xml:"DescribeStacksResult"`
+}
+
+type describeStacksResult struct {
+	Stacks []stackMember `xml:"Stacks>member"`
+}
+
+type stackMember struct {
+	StackName    string         `xml:"StackName"`
+	StackId      string         `xml:"StackId"`
+	StackStatus  string         `xml:"StackStatus"`
+	CreationTime string         `xml:"CreationTime"`
+	Outputs      []outputMember `xml:"Outputs>member"`
+}
+
+type outputMember struct {
+	OutputKey   string `xml:"OutputKey"`
+	OutputValue string `xml:"OutputValue"`
+	ExportName  string `xml:"ExportName"`
+}
+
+type errorResponse struct {
+	XMLName xml.Name    `xml:"ErrorResponse"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	Error   errorDetail `xml:"Error"`
+}
+
+type errorDetail struct {
+	Type    string `xml:"Type"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (s *Server) serveCloudFormation(w http.ResponseWriter, r *http.Request, action string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if code := s.failure("CloudFormation", action); code != "" {
+		writeCloudFormationError(w, code, "injected failure of "+action)
+		return
+	}
+
+	switch action {
+	case "DescribeStacks":
+		s.describeStacks(w, r.PostForm.Get("StackName"))
+	default:
+		writeCloudFormationError(w, "InvalidAction", "unsupported action "+action)
+	}
+}
+
+func (s *Server) describeStacks(w http.ResponseWriter, stackName string) {
+	st, ok := s.stacks[stackName]
+	if !ok {
+		writeCloudFormationError(w, "ValidationError", "Stack with id "+stackName+" does not exist")
+		return
+	}
+
+	member := stackMember{
+		StackName:    st.name,
+		StackId:      st.id,
+		StackStatus:  st.status,
+		CreationTime: "2023-01-01T00:00:00Z",
+	}
+	for _, output := range st.outputs {
+		member.Outputs = append(member.Outputs, outputMember{
+			OutputKey:   output.key,
+			OutputValue: output.value,
+			ExportName:  output.exportName,
+		})
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	xml.NewEncoder(w).Encode(&describeStacksResponse{
+		Xmlns:  cloudFormationNamespace,
+		Result: describeStacksResult{Stacks: []stackMember{member}},
+	})
+}
+
+func writeCloudFormationError(w http.ResponseWriter, code string, message string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusBadRequest)
+	xml.NewEncoder(w).Encode(&errorResponse{
+		Xmlns: cloudFormationNamespace,
+		Error: errorDetail{
+			Type:    "Sender",
+			Code:    code,
+			Message: message,
+		},
+	})
+}
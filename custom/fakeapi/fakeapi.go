@@ -0,0 +1,438 @@
+// Package fakeapi is a local fake of the App Runner and CloudFormation APIs used by the Lambda functions,
+// served by httptest for custom/localrun and the tests of the handlers.
+// The service operations can be made slow (OperationDuration) or failed (FailOperations),
+// and any action can be made to return an error (FailRequest).
+package fakeapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	Region    = "ap-northeast-1"
+	AccountID = "123456789012"
+)
+
+const responsePath = "/cfn-response"
+
+// AutoScalingConfiguration is a revision of an AutoScalingConfiguration in the fake.
+type AutoScalingConfiguration struct {
+	Arn            string
+	Name           string
+	Revision       int32
+	Latest         bool
+	Status         string // ACTIVE or INACTIVE
+	MaxConcurrency int32
+	MaxSize        int32
+	MinSize        int32
+	CreatedAt      time.Time
+	DeletedAt      time.Time
+	Tags           map[string]string
+	// IsDefault is the account default, which the services created without an AutoScalingConfiguration use.
+	IsDefault bool
+}
+
+// Service is an App Runner service in the fake.
+type Service struct {
+	Arn                         string
+	Name                        string
+	ID                          string
+	Status                      string // RUNNING, PAUSED or DELETED
+	AutoScalingConfigurationArn string
+}
+
+type operation struct {
+	id                          string
+	operationType               string
+	serviceArn                  string
+	startedAt                   time.Time
+	endsAt                      time.Time
+	failed                      bool
+	settled                     bool
+	autoScalingConfigurationArn string
+	serviceStatus               string // the status of the service after PAUSE_SERVICE or RESUME_SERVICE
+}
+
+func (o *operation) status(now time.Time) string {
+	if now.Before(o.endsAt) {
+		return "IN_PROGRESS"
+	}
+	if o.failed {
+		return "FAILED"
+	}
+	return "SUCCEEDED"
+}
+
+type stack struct {
+	name    string
+	id      string
+	status  string
+	outputs []stackOutput
+}
+
+type stackOutput struct {
+	key        string
+	value      string
+	exportName string
+}
+
+type Server struct {
+	*httptest.Server
+
+	// OperationDuration is how long the operations of the services stay IN_PROGRESS.
+	OperationDuration time.Duration
+	// FailOperations makes the operations of the services end with FAILED, leaving the services unchanged.
+	FailOperations bool
+	// PageSize splits the lists of the AutoScalingConfigurations and the services into the pages of the size.
+	PageSize int
+
+	mu             sync.Mutex
+	nextID         int
+	configurations []*AutoScalingConfiguration
+	services       map[string]*Service
+	operations     map[string][]*operation
+	stacks         map[string]*stack
+	failures       map[string]string
+	calls          []string
+	responses      []*cfn.Response
+}
+
+// NewServer starts the fake with DefaultConfiguration as the account default, which every account has.
+func NewServer() *Server {
+	s := &Server{
+		services:   map[string]*Service{},
+		operations: map[string][]*operation{},
+		stacks:     map[string]*stack{},
+		failures:   map[string]string{},
+	}
+	s.createAutoScalingConfiguration("DefaultConfiguration", 100, 25, 1).IsDefault = true
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Config returns the config of the SDK clients pointed at the fake without credentials and retries.
+func (s *Server) Config() aws.Config {
+	return aws.Config{
+		Region:      Region,
+		Credentials: aws.AnonymousCredentials{},
+		EndpointResolverWithOptions: aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+			return aws.Endpoint{URL: s.URL, HostnameImmutable: true}, nil
+		}),
+		Retryer: func() aws.Retryer {
+			return aws.NopRetryer{}
+		},
+	}
+}
+
+// ResponseURL is the URL to set to ResponseURL of cfn.Event, where cfn.LambdaWrap sends the response.
+func (s *Server) ResponseURL() string {
+	return s.URL + responsePath
+}
+
+// AddStack adds a stack with the services on the account default AutoScalingConfiguration,
+// whose ARNs are exported as "<StackName>AppRunnerService<Name>ServiceArn" like the CDK stack, and returns the service ARNs.
+func (s *Server) AddStack(stackName string, serviceNames ...string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := &stack{
+		name:   stackName,
+		id:     fmt.Sprintf("arn:aws:cloudformation:%s:%s:stack/%s/%s", Region, AccountID, stackName, s.newID()),
+		status: "UPDATE_IN_PROGRESS",
+	}
+
+	serviceArns := []string{}
+	for _, serviceName := range serviceNames {
+		service := &Service{
+			Name:                        stackName + "-" + serviceName,
+			ID:                          s.newID(),
+			Status:                      "RUNNING",
+			AutoScalingConfigurationArn: s.accountDefault().Arn,
+		}
+		service.Arn = fmt.Sprintf("arn:aws:apprunner:%s:%s:service/%s/%s", Region, AccountID, service.Name, service.ID)
+		s.services[service.Arn] = service
+
+		st.outputs = append(st.outputs, stackOutput{
+			key:        "AppRunnerService" + serviceName + "ServiceArn",
+			value:      service.Arn,
+			exportName: stackName + "AppRunnerService" + serviceName + "ServiceArn",
+		})
+		serviceArns = append(serviceArns, service.Arn)
+	}
+
+	s.stacks[stackName] = st
+	return serviceArns
+}
+
+// SetStackStatus sets the status of the stack such as UPDATE_ROLLBACK_IN_PROGRESS.
+func (s *Server) SetStackStatus(stackName string, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st, ok := s.stacks[stackName]; ok {
+		st.status = status
+	}
+}
+
+// FailRequest makes the action (e.g. "DeleteAutoScalingConfiguration") return the error code until ClearFailures.
+func (s *Server) FailRequest(action string, errorCode string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures[action] = errorCode
+}
+
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures = map[string]string{}
+}
+
+// AutoScalingConfigurations returns all the revisions, including the deleted (INACTIVE) ones.
+func (s *Server) AutoScalingConfigurations() []AutoScalingConfiguration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	configurations := []AutoScalingConfiguration{}
+	for _, configuration := range s.configurations {
+		copied := *configuration
+		copied.Tags = map[string]string{}
+		for key, value := range configuration.Tags {
+			copied.Tags[key] = value
+		}
+		configurations = append(configurations, copied)
+	}
+	return configurations
+}
+
+func (s *Server) Service(serviceArn string) (Service, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.settleOperations(time.Now())
+	service, ok := s.services[serviceArn]
+	if !ok {
+		return Service{}, false
+	}
+	return *service, true
+}
+
+// SetServiceAutoScalingConfiguration sets the AutoScalingConfiguration of the service without an operation,
+// as CloudFormation does when it updates the services referring to the custom resource.
+func (s *Server) SetServiceAutoScalingConfiguration(serviceArn string, autoScalingConfigurationArn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if service, ok := s.services[serviceArn]; ok {
+		service.AutoScalingConfigurationArn = autoScalingConfigurationArn
+	}
+}
+
+// SetServiceStatus sets the status of the service without an operation, e.g. PAUSED from the console.
+func (s *Server) SetServiceStatus(serviceArn string, status string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if service, ok := s.services[serviceArn]; ok {
+		service.Status = status
+	}
+}
+
+// DeleteService marks the service DELETED, as CloudFormation deletes the services before the custom resource on the stack deletion.
+func (s *Server) DeleteService(serviceArn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if service, ok := s.services[serviceArn]; ok {
+		service.Status = "DELETED"
+	}
+}
+
+// AddAutoScalingConfiguration creates a revision outside of the handler, e.g. a shared AutoScalingConfiguration.
+func (s *Server) AddAutoScalingConfiguration(name string, maxConcurrency int32, maxSize int32, minSize int32) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.createAutoScalingConfiguration(name, maxConcurrency, maxSize, minSize).Arn
+}
+
+// DefaultAutoScalingConfigurationArn is the ARN of DefaultConfiguration.
+func (s *Server) DefaultAutoScalingConfigurationArn() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.configurations[0].Arn
+}
+
+// AccountDefaultAutoScalingConfigurationArn is the ARN of the account default revision (IsDefault).
+func (s *Server) AccountDefaultAutoScalingConfigurationArn() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.accountDefault().Arn
+}
+
+// SetAccountDefault makes the revision the account default outside of the handler.
+func (s *Server) SetAccountDefault(autoScalingConfigurationArn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, configuration := range s.configurations {
+		configuration.IsDefault = configuration.Arn == autoScalingConfigurationArn
+	}
+}
+
+// It must be called with the lock held. DefaultConfiguration is the default until another revision is made the default.
+func (s *Server) accountDefault() *AutoScalingConfiguration {
+	for _, configuration := range s.configurations {
+		if configuration.IsDefault {
+			return configuration
+		}
+	}
+	return s.configurations[0]
+}
+
+// Calls returns the called actions in order, e.g. "AppRunner.UpdateService" and "CloudFormation.DescribeStacks".
+func (s *Server) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.calls...)
+}
+
+// Responses returns the custom resource responses sent to ResponseURL.
+func (s *Server) Responses() []*cfn.Response {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*cfn.Response{}, s.responses...)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == responsePath {
+		s.serveResponse(w, r)
+		return
+	}
+
+	if target := r.Header.Get("X-Amz-Target"); strings.HasPrefix(target, "AppRunner.") {
+		s.serveAppRunner(w, r, strings.TrimPrefix(target, "AppRunner."))
+		return
+	}
+
+	if err := r.ParseForm(); err == nil && r.PostForm.Get("Action") != "" {
+		s.serveCloudFormation(w, r, r.PostForm.Get("Action"))
+		return
+	}
+
+	http.Error(w, "unsupported request", http.StatusBadRequest)
+}
+
+func (s *Server) serveResponse(w http.ResponseWriter, r *http.Request) {
+	response := &cfn.Response{}
+	if err := json.NewDecoder(r.Body).Decode(response); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.responses = append(s.responses, response)
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// failure records the call and returns the error code set by FailRequest.
+// It must be called with the lock held.
+func (s *Server) failure(service string, action string) string {
+	s.calls = append(s.calls, service+"."+action)
+	return s.failures[action]
+}
+
+// It must be called with the lock held.
+func (s *Server) newID() string {
+	s.nextID++
+	return fmt.Sprintf("%032x", s.nextID)
+}
+
+// It must be called with the lock held.
+func (s *Server) createAutoScalingConfiguration(name string, maxConcurrency int32, maxSize int32, minSize int32) *AutoScalingConfiguration {
+	revision := int32(1)
+	for _, configuration := range s.configurations {
+		if configuration.Name != name {
+			continue
+		}
+		if configuration.Revision >= revision {
+			revision = configuration.Revision + 1
+		}
+		configuration.Latest = false
+	}
+
+	id := s.newID()
+	configuration := &AutoScalingConfiguration{
+		Arn:            fmt.Sprintf("arn:aws:apprunner:%s:%s:autoscalingconfiguration/%s/%d/%s", Region, AccountID, name, revision, id),
+		Name:           name,
+		Revision:       revision,
+		Latest:         true,
+		Status:         "ACTIVE",
+		MaxConcurrency: maxConcurrency,
+		MaxSize:        maxSize,
+		MinSize:        minSize,
+		CreatedAt:      time.Now(),
+		Tags:           map[string]string{},
+	}
+	s.configurations = append(s.configurations, configuration)
+	return configuration
+}
+
+// settleOperations applies the succeeded operations to the services.
+// It must be called with the lock held.
+func (s *Server) settleOperations(now time.Time) {
+	for _, operations := range s.operations {
+		for _, op := range operations {
+			if op.settled || op.status(now) == "IN_PROGRESS" {
+				continue
+			}
+			op.settled = true
+			if op.status(now) != "SUCCEEDED" {
+				continue
+			}
+			if op.autoScalingConfigurationArn != "" {
+				s.services[op.serviceArn].AutoScalingConfigurationArn = op.autoScalingConfigurationArn
+			}
+			if op.serviceStatus != "" {
+				s.services[op.serviceArn].Status = op.serviceStatus
+			}
+		}
+	}
+}
+
+// It must be called with the lock held.
+func (s *Server) inProgress(serviceArn string, now time.Time) bool {
+	for _, op := range s.operations[serviceArn] {
+		if op.status(now) == "IN_PROGRESS" {
+			return true
+		}
+	}
+	return false
+}
+
+// It must be called with the lock held.
+func (s *Server) sortedServiceArns() []string {
+	arns := make([]string, 0, len(s.services))
+	for arn := range s.services {
+		arns = append(arns, arn)
+	}
+	sort.Strings(arns)
+	return arns
+}
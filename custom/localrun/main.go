@@ -0,0 +1,236 @@
+// localrun runs the handler of Custom::AutoScalingConfiguration against the fake App Runner and CloudFormation APIs
+// of custom/fakeapi, without AWS credentials. The events are replayed in order on the same fake state,
+// so a rollback sequence can be reproduced from the events of a real deployment:
+//
+//	go run ./localrun [-stack AppRunnerGoStack] [-services L2,L1] [-retain-services L1] [-operation-duration 3s] [-fail-operations] [-fail Action=ErrorCode] event.json [event.json...]
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-cdk-go-managed-apprunner/custom/apprunnerops"
+	"go-cdk-go-managed-apprunner/custom/autoscaling"
+	"go-cdk-go-managed-apprunner/custom/fakeapi"
+	"go-cdk-go-managed-apprunner/custom/logging"
+	"go-cdk-go-managed-apprunner/custom/metrics"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// failures is the -fail flag, which can be repeated.
+type failures map[string]string
+
+func (f failures) String() string {
+	pairs := []string{}
+	for action, code := range f {
+		pairs = append(pairs, action+"="+code)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f failures) Set(value string) error {
+	action, code, ok := strings.Cut(value, "=")
+	if !ok || action == "" || code == "" {
+		return fmt.Errorf("-fail must be Action=ErrorCode: %s", value)
+	}
+	f[action] = code
+	return nil
+}
+
+func main() {
+	stackName := flag.String("stack", "AppRunnerGoStack", "name of the stack, which is replaced with StackName of the events if they have")
+	serviceNames := flag.String("services", "L2,L1", "comma-separated names of the services exported as <StackName>AppRunnerService<Name>ServiceArn")
+	operationDuration := flag.Duration("operation-duration", 0, "how long UpdateService operations stay IN_PROGRESS")
+	failOperations := flag.Bool("fail-operations", false, "make UpdateService operations end with FAILED")
+	pollInterval := flag.Duration("poll-interval", 500*time.Millisecond, "interval to poll the operations")
+	retainServices := flag.String("retain-services", "", "comma-separated names of the services retained on the stack deletion, which are not deleted before Delete")
+	follow := flag.Bool("follow", true, "move the services to AutoScalingConfigurationArn of the responses, as CloudFormation does for the services referring to it")
+	failedRequests := failures{}
+	flag.Var(failedRequests, "fail", "make the action return the error code, e.g. DeleteAutoScalingConfiguration=InvalidRequestException (repeatable)")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	events := []*cfn.Event{}
+	for _, path := range flag.Args() {
+		event, err := readEvent(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if name, ok := event.ResourceProperties["StackName"].(string); ok {
+			*stackName = name
+		}
+		events = append(events, event)
+	}
+
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.OperationDuration = *operationDuration
+	server.FailOperations = *failOperations
+	for action, code := range failedRequests {
+		server.FailRequest(action, code)
+	}
+	serviceArns := server.AddStack(*stackName, strings.Split(*serviceNames, ",")...)
+
+	apprunnerops.OperationPollInterval = *pollInterval
+	cfg := server.Config()
+	logging.AddAPICallLogging(&cfg)
+	metrics.AddRetryCounting(&cfg)
+	handler := autoscaling.NewHandler(
+		apprunner.NewFromConfig(cfg),
+		cloudformation.NewFromConfig(cfg),
+	)
+	lambdaFunction := cfn.LambdaWrap(handler.HandleRequest)
+
+	ctx := context.Background()
+	failed := false
+	physicalResourceIDs := &physicalResourceIDs{byProperties: map[string]string{}}
+	for i, event := range events {
+		event.ResponseURL = server.ResponseURL()
+		physicalResourceIDs.rewrite(event)
+
+		fmt.Printf("=== %s (%s)\n", event.RequestType, flag.Arg(i))
+		if *follow && event.RequestType == cfn.RequestDelete && event.PhysicalResourceID == physicalResourceIDs.current {
+			deleteServices(server, *stackName, serviceArns, strings.Split(*retainServices, ","))
+		}
+		if _, err := lambdaFunction(ctx, *event); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		responses := server.Responses()
+		response := responses[len(responses)-1]
+		if response.Status != cfn.StatusSuccess {
+			failed = true
+		}
+		printResponse(response)
+
+		if response.Status == cfn.StatusSuccess {
+			physicalResourceIDs.record(event, response)
+			if *follow {
+				followResponse(server, serviceArns, event, response)
+			}
+		}
+		printState(server, serviceArns)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func readEvent(path string) (*cfn.Event, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &cfn.Event{}
+	if err := json.Unmarshal(body, event); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return event, nil
+}
+
+// physicalResourceIDs replaces the physical IDs of the events, which are of the real deployment, with the ones of the fake
+// as CloudFormation does: Update is sent to the current resource, and Delete to the resource with the properties
+// (the old resource in the cleanup after an update or a rollback, otherwise the current one).
+type physicalResourceIDs struct {
+	current      string
+	byProperties map[string]string
+}
+
+func propertiesKey(properties map[string]interface{}) string {
+	return fmt.Sprint(properties["AutoScalingConfigurationName"], "/", properties["MaxConcurrency"], "/", properties["MaxSize"], "/", properties["MinSize"])
+}
+
+func (p *physicalResourceIDs) rewrite(event *cfn.Event) {
+	switch event.RequestType {
+	case cfn.RequestUpdate:
+		if p.current != "" {
+			event.PhysicalResourceID = p.current
+		}
+	case cfn.RequestDelete:
+		if id, ok := p.byProperties[propertiesKey(event.ResourceProperties)]; ok {
+			event.PhysicalResourceID = id
+		} else if p.current != "" {
+			event.PhysicalResourceID = p.current
+		}
+	}
+}
+
+func (p *physicalResourceIDs) record(event *cfn.Event, response *cfn.Response) {
+	if event.RequestType == cfn.RequestDelete {
+		delete(p.byProperties, propertiesKey(event.ResourceProperties))
+		return
+	}
+	p.current = response.PhysicalResourceID
+	p.byProperties[propertiesKey(event.ResourceProperties)] = response.PhysicalResourceID
+}
+
+// followResponse simulates CloudFormation updating the services referring to AutoScalingConfigurationArn
+// after Create and Update of the custom resource.
+func followResponse(server *fakeapi.Server, serviceArns []string, event *cfn.Event, response *cfn.Response) {
+	autoScalingConfigurationArn, _ := response.Data["AutoScalingConfigurationArn"].(string)
+	if event.RequestType == cfn.RequestDelete || autoScalingConfigurationArn == "" {
+		return
+	}
+
+	for _, serviceArn := range serviceArns {
+		server.SetServiceAutoScalingConfiguration(serviceArn, autoScalingConfigurationArn)
+	}
+}
+
+// deleteServices simulates CloudFormation deleting the services, which depend on the custom resource,
+// before Delete of the current resource on the stack deletion.
+func deleteServices(server *fakeapi.Server, stackName string, serviceArns []string, retainedServiceNames []string) {
+	retained := map[string]bool{}
+	for _, name := range retainedServiceNames {
+		retained[stackName+"-"+name] = true
+	}
+
+	for _, serviceArn := range serviceArns {
+		if service, ok := server.Service(serviceArn); ok && !retained[service.Name] {
+			server.DeleteService(serviceArn)
+		}
+	}
+}
+
+func printResponse(response *cfn.Response) {
+	fmt.Printf("Status: %s\n", response.Status)
+	fmt.Printf("PhysicalResourceId: %s\n", response.PhysicalResourceID)
+	if response.Reason != "" {
+		fmt.Printf("Reason: %s\n", response.Reason)
+	}
+	for key, value := range response.Data {
+		fmt.Printf("Data.%s: %v\n", key, value)
+	}
+}
+
+func printState(server *fakeapi.Server, serviceArns []string) {
+	fmt.Println("AutoScalingConfigurations:")
+	for _, configuration := range server.AutoScalingConfigurations() {
+		accountDefault := ""
+		if configuration.IsDefault {
+			accountDefault = " account default"
+		}
+		fmt.Printf("  %s %s%s (MaxConcurrency=%d MaxSize=%d MinSize=%d)\n",
+			configuration.Arn, configuration.Status, accountDefault, configuration.MaxConcurrency, configuration.MaxSize, configuration.MinSize)
+	}
+	fmt.Println("Services:")
+	for _, serviceArn := range serviceArns {
+		service, _ := server.Service(serviceArn)
+		fmt.Printf("  %s (%s) -> %s\n", service.Name, service.Status, service.AutoScalingConfigurationArn)
+	}
+}
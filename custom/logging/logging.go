@@ -0,0 +1,85 @@
+// Package logging writes structured JSON logs, one object per line, for CloudWatch Logs Insights.
+// The logger carries the correlation fields (e.g. RequestId of CloudFormation) and is passed through the context,
+// so that apprunnerops and the SDK middleware (AddAPICallLogging) log with the fields of the request.
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+type Logger struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	fields []interface{}
+}
+
+func New(w io.Writer) *Logger {
+	return &Logger{
+		mu: &sync.Mutex{},
+		w:  w,
+	}
+}
+
+// nop is returned by FromContext without a logger, e.g. for the CLI commands.
+var nop = New(io.Discard)
+
+// With returns a logger with the key-value pairs added to every log.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	return &Logger{
+		mu:     l.mu,
+		w:      l.w,
+		fields: append(append([]interface{}{}, l.fields...), keyvals...),
+	}
+}
+
+func (l *Logger) Info(message string, keyvals ...interface{}) {
+	l.log("INFO", message, keyvals)
+}
+
+func (l *Logger) Error(message string, keyvals ...interface{}) {
+	l.log("ERROR", message, keyvals)
+}
+
+func (l *Logger) log(level string, message string, keyvals []interface{}) {
+	entry := map[string]interface{}{}
+	for _, pairs := range [][]interface{}{l.fields, keyvals} {
+		for i := 0; i+1 < len(pairs); i += 2 {
+			entry[fmt.Sprint(pairs[i])] = pairs[i+1]
+		}
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level
+	entry["message"] = message
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]interface{}{
+			"time":    entry["time"],
+			"level":   "ERROR",
+			"message": fmt.Sprintf("failed to marshal the log of %q: %v", message, err),
+		})
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(line, '\n'))
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, logger *Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of the context, or a logger writing nothing if there is none.
+func FromContext(ctx context.Context) *Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*Logger); ok {
+		return logger
+	}
+	return nop
+}
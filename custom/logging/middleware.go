@@ -0,0 +1,48 @@
+package logging
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// AddAPICallLogging makes the clients created from the config log every API call and its duration
+// with the logger of the context of the call.
+func AddAPICallLogging(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(apiCallLogging, middleware.After)
+	})
+}
+
+// apiCallLogging is added to the Initialize step, which covers the retries of the call.
+var apiCallLogging = middleware.InitializeMiddlewareFunc("APICallLogging", func(
+	ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+) (middleware.InitializeOutput, middleware.Metadata, error) {
+	start := time.Now()
+	out, metadata, err := next.HandleInitialize(ctx, in)
+
+	keyvals := []interface{}{
+		"Service", awsmiddleware.GetServiceID(ctx),
+		"Operation", awsmiddleware.GetOperationName(ctx),
+		"DurationMs", time.Since(start).Milliseconds(),
+	}
+	if requestID, ok := awsmiddleware.GetRequestIDMetadata(metadata); ok {
+		keyvals = append(keyvals, "AwsRequestId", requestID)
+	}
+	if attempts, ok := retry.GetAttemptResults(metadata); ok {
+		keyvals = append(keyvals, "Attempts", len(attempts.Results))
+	}
+
+	logger := FromContext(ctx)
+	if err != nil {
+		logger.Error("AWS API call failed", append(keyvals, "Error", err.Error())...)
+	} else {
+		logger.Info("AWS API call", keyvals...)
+	}
+
+	return out, metadata, err
+})
@@ -0,0 +1,147 @@
+// Package metrics writes CloudWatch metrics in the embedded metric format (EMF), one JSON object per line,
+// which CloudWatch Logs extracts from the logs of the Lambda functions without PutMetricData.
+// The recorder is passed through the context like the logger of custom/logging.
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultNamespace is the namespace of the metrics unless METRICS_NAMESPACE is set to the Lambda function.
+const DefaultNamespace = "AppRunnerCustomResource"
+
+const (
+	OperationWaitTime = "OperationWaitTime"
+	ServicesUpdated   = "ServicesUpdated"
+	Retries           = "Retries"
+	Failures          = "Failures"
+	Duration          = "Duration"
+)
+
+var units = map[string]string{
+	OperationWaitTime: "Milliseconds",
+	ServicesUpdated:   "Count",
+	Retries:           "Count",
+	Failures:          "Count",
+	Duration:          "Milliseconds",
+}
+
+// Recorder counts the metrics of a request, dimensioned by StackName and RequestType.
+type Recorder struct {
+	mu          sync.Mutex
+	w           io.Writer
+	namespace   string
+	stackName   string
+	requestType string
+	counts      map[string]float64
+}
+
+func New(w io.Writer, namespace string, stackName string, requestType string) *Recorder {
+	return &Recorder{
+		w:           w,
+		namespace:   namespace,
+		stackName:   stackName,
+		requestType: requestType,
+		counts: map[string]float64{
+			ServicesUpdated: 0,
+			Retries:         0,
+			Failures:        0,
+		},
+	}
+}
+
+// nop is returned by FromContext without a recorder.
+var nop = New(io.Discard, DefaultNamespace, "", "")
+
+// Add adds the value to the metric written by Flush.
+func (r *Recorder) Add(metricName string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.counts[metricName] += value
+}
+
+// PutOperationWaitTime writes the time the operation of the service took, also dimensioned by ServiceName.
+func (r *Recorder) PutOperationWaitTime(serviceName string, d time.Duration) {
+	r.write(map[string]float64{OperationWaitTime: float64(d.Milliseconds())}, serviceName)
+}
+
+// Flush writes the counted metrics with the duration of the request.
+// ServicesUpdated, Retries and Failures are written even when 0, so that the alarms see the requests without failures.
+func (r *Recorder) Flush(d time.Duration) {
+	r.mu.Lock()
+	values := map[string]float64{Duration: float64(d.Milliseconds())}
+	for metricName, value := range r.counts {
+		values[metricName] = value
+	}
+	r.mu.Unlock()
+
+	r.write(values, "")
+}
+
+// write writes an EMF object. The metrics are dimensioned by [StackName, RequestType] and [StackName],
+// or by [StackName, RequestType, ServiceName] and [StackName, RequestType] for a service.
+func (r *Recorder) write(values map[string]float64, serviceName string) {
+	entry := map[string]interface{}{
+		"StackName":   r.stackName,
+		"RequestType": r.requestType,
+	}
+	dimensions := [][]string{{"StackName", "RequestType"}, {"StackName"}}
+	if serviceName != "" {
+		entry["ServiceName"] = serviceName
+		dimensions = [][]string{{"StackName", "RequestType", "ServiceName"}, {"StackName", "RequestType"}}
+	}
+
+	metricNames := make([]string, 0, len(values))
+	for metricName := range values {
+		metricNames = append(metricNames, metricName)
+	}
+	sort.Strings(metricNames)
+
+	definitions := []map[string]string{}
+	for _, metricName := range metricNames {
+		entry[metricName] = values[metricName]
+		definitions = append(definitions, map[string]string{
+			"Name": metricName,
+			"Unit": units[metricName],
+		})
+	}
+	entry["_aws"] = map[string]interface{}{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []map[string]interface{}{
+			{
+				"Namespace":  r.namespace,
+				"Dimensions": dimensions,
+				"Metrics":    definitions,
+			},
+		},
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.w.Write(append(line, '\n'))
+}
+
+type contextKey struct{}
+
+func NewContext(ctx context.Context, recorder *Recorder) context.Context {
+	return context.WithValue(ctx, contextKey{}, recorder)
+}
+
+// FromContext returns the recorder of the context, or a recorder writing nothing if there is none.
+func FromContext(ctx context.Context) *Recorder {
+	if recorder, ok := ctx.Value(contextKey{}).(*Recorder); ok {
+		return recorder
+	}
+	return nop
+}
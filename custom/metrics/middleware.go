@@ -0,0 +1,29 @@
+package metrics
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/smithy-go/middleware"
+)
+
+// AddRetryCounting makes the clients created from the config add the retries of every API call
+// to Retries of the recorder of the context of the call.
+func AddRetryCounting(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(retryCounting, middleware.After)
+	})
+}
+
+var retryCounting = middleware.InitializeMiddlewareFunc("RetryCounting", func(
+	ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler,
+) (middleware.InitializeOutput, middleware.Metadata, error) {
+	out, metadata, err := next.HandleInitialize(ctx, in)
+
+	if attempts, ok := retry.GetAttemptResults(metadata); ok && len(attempts.Results) > 1 {
+		FromContext(ctx).Add(Retries, float64(len(attempts.Results)-1))
+	}
+
+	return out, metadata, err
+})
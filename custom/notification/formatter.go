@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	serviceStatusChange          = "AppRunner Service Status Change"
+	serviceOperationStatusChange = "AppRunner Service Operation Status Change"
+
+	// SNS rejects subjects longer than 100 characters.
+	maxSubjectLength = 100
+)
+
+type Notification struct {
+	Subject string
+	Message string
+}
+
+type serviceEventDetail struct {
+	PreviousStatus  string `json:"previousStatus"`
+	CurrentStatus   string `json:"currentStatus"`
+	OperationStatus string `json:"operationStatus"`
+	ServiceName     string `json:"serviceName"`
+	ServiceID       string `json:"serviceId"`
+	Message         string `json:"message"`
+	Severity        string `json:"severity"`
+}
+
+func formatEvent(event events.CloudWatchEvent) (*Notification, error) {
+	var detail serviceEventDetail
+	if err := json.Unmarshal(event.Detail, &detail); err != nil {
+		return nil, fmt.Errorf("Detail Unmarshal Error: %v", err)
+	}
+
+	var status string
+	switch event.DetailType {
+	case serviceStatusChange:
+		status = detail.PreviousStatus + " -> " + detail.CurrentStatus
+	case serviceOperationStatusChange:
+		status = detail.OperationStatus
+	default:
+		return nil, fmt.Errorf("Unsupported DetailType: %s", event.DetailType)
+	}
+
+	severity := detail.Severity
+	if severity == "" {
+		severity = "INFO"
+	}
+
+	subject := truncate(fmt.Sprintf("[%s] App Runner %s: %s", severity, detail.ServiceName, status), maxSubjectLength)
+
+	lines := []string{
+		subject,
+		detail.Message,
+		fmt.Sprintf("Region: %s, Time: %s", event.Region, event.Time.UTC().Format("2006-01-02T15:04:05Z")),
+	}
+	if len(event.Resources) > 0 {
+		lines = append(lines, "Service: "+event.Resources[0])
+	}
+
+	return &Notification{
+		Subject: subject,
+		Message: strings.Join(lines, "\n"),
+	}, nil
+}
+
+// webhookPayload renders the message as Slack compatible JSON.
+func webhookPayload(notification *Notification) ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"text": notification.Message,
+	})
+}
+
+// truncate cuts s to at most n bytes on a rune boundary, so that the result stays valid UTF-8.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func loadEvent(t *testing.T, name string) events.CloudWatchEvent {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var event events.CloudWatchEvent
+	if err := json.Unmarshal(b, &event); err != nil {
+		t.Fatal(err)
+	}
+
+	return event
+}
+
+func TestFormatEvent(t *testing.T) {
+	cases := []struct {
+		name        string
+		file        string
+		wantSubject string
+		wantMessage string
+		wantErr     bool
+	}{
+		{
+			name:        "service status change",
+			file:        "service_status_change.json",
+			wantSubject: "[INFO] App Runner AppRunnerServiceL1: OPERATION_IN_PROGRESS -> RUNNING",
+			wantMessage: "[INFO] App Runner AppRunnerServiceL1: OPERATION_IN_PROGRESS -> RUNNING\n" +
+				"Service status is set to RUNNING.\n" +
+				"Region: ap-northeast-1, Time: 2023-06-10T11:54:23Z\n" +
+				"Service: arn:aws:apprunner:ap-northeast-1:123456789012:service/AppRunnerServiceL1/8fe1e10304f84fd2b0df550fe98a71fa",
+		},
+		{
+			name:        "deployment failed",
+			file:        "deployment_failed.json",
+			wantSubject: "[ERROR] App Runner AppRunnerServiceL2: DeploymentFailed",
+			wantMessage: "[ERROR] App Runner AppRunnerServiceL2: DeploymentFailed\n" +
+				"Deployment failed.\n" +
+				"Region: ap-northeast-1, Time: 2023-06-10T12:10:05Z\n" +
+				"Service: arn:aws:apprunner:ap-northeast-1:123456789012:service/AppRunnerServiceL2/0f3a9b1c2d4e4f5a8b7c6d5e4f3a2b1c",
+		},
+		{
+			name:    "unsupported detail type",
+			file:    "unsupported.json",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			notification, err := formatEvent(loadEvent(t, tc.file))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if notification.Subject != tc.wantSubject {
+				t.Errorf("Subject = %q, want %q", notification.Subject, tc.wantSubject)
+			}
+			if notification.Message != tc.wantMessage {
+				t.Errorf("Message = %q, want %q", notification.Message, tc.wantMessage)
+			}
+		})
+	}
+}
+
+func TestFormatEventLongSubject(t *testing.T) {
+	event := loadEvent(t, "service_status_change.json")
+	detail, err := json.Marshal(map[string]string{
+		"previousStatus": "OPERATION_IN_PROGRESS",
+		"currentStatus":  "RUNNING",
+		"serviceName":    strings.Repeat("サービス", 20),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event.Detail = detail
+
+	notification, err := formatEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notification.Subject) > maxSubjectLength {
+		t.Errorf("Subject of %d bytes, want at most %d", len(notification.Subject), maxSubjectLength)
+	}
+	if !utf8.ValidString(notification.Subject) {
+		t.Errorf("Subject %q is not valid UTF-8", notification.Subject)
+	}
+}
+
+func TestWebhookPayload(t *testing.T) {
+	payload, err := webhookPayload(&Notification{
+		Subject: "subject",
+		Message: "line1\nline2",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{"text":"line1\nline2"}`
+	if string(payload) != want {
+		t.Errorf("payload = %s, want %s", payload, want)
+	}
+}
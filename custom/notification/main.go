@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+)
+
+// webhookUrl is cached across the invocations of a warm Lambda, so that the secret is read once.
+var webhookUrl string
+
+func HandleRequest(ctx context.Context, event events.CloudWatchEvent) error {
+	notification, err := formatEvent(event)
+	if err != nil {
+		return err
+	}
+
+	topicArn := os.Getenv("TOPIC_ARN")
+	secretId := os.Getenv("WEBHOOK_URL_SECRET_ID")
+	if topicArn == "" && secretId == "" {
+		return nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(os.Getenv("AWS_REGION")))
+	if err != nil {
+		return err
+	}
+
+	if topicArn != "" {
+		if err := publish(ctx, sns.NewFromConfig(cfg), topicArn, notification); err != nil {
+			return err
+		}
+	}
+
+	if secretId != "" {
+		if webhookUrl == "" {
+			webhookUrl, err = getSecretString(ctx, secretsmanager.NewFromConfig(cfg), secretId)
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := postWebhook(ctx, webhookUrl, notification); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func publish(ctx context.Context, client *sns.Client, topicArn string, notification *Notification) error {
+	_, err := client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(topicArn),
+		Subject:  aws.String(notification.Subject),
+		Message:  aws.String(notification.Message),
+	})
+
+	return err
+}
+
+func getSecretString(ctx context.Context, client *secretsmanager.Client, secretId string) (string, error) {
+	output, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretId),
+	})
+	if err != nil {
+		return "", err
+	}
+	if aws.ToString(output.SecretString) == "" {
+		return "", fmt.Errorf("Secret %s has no SecretString", secretId)
+	}
+
+	return aws.ToString(output.SecretString), nil
+}
+
+func postWebhook(ctx context.Context, webhookUrl string, notification *Notification) error {
+	payload, err := webhookPayload(notification)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookUrl, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Webhook Error status:%d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}
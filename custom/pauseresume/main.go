@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go-cdk-go-managed-apprunner/custom/apprunnerops"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgeTypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+)
+
+const (
+	summaryEventSource     = "go-cdk-go-managed-apprunner"
+	summaryEventDetailType = "AppRunner Pause Resume Summary"
+)
+
+// PauseResumeEvent is the input of the EventBridge Scheduler schedules.
+type PauseResumeEvent struct {
+	Action    string
+	StackName string
+}
+
+type Summary struct {
+	StackName string
+	Action    string
+	Results   []*apprunnerops.PauseResumeResult
+}
+
+func HandleRequest(ctx context.Context, event PauseResumeEvent) (*Summary, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(os.Getenv("AWS_REGION")))
+	if err != nil {
+		return nil, err
+	}
+
+	return run(ctx, cfg, event)
+}
+
+func run(ctx context.Context, cfg aws.Config, event PauseResumeEvent) (*Summary, error) {
+	apprunnerClient := apprunner.NewFromConfig(cfg)
+	cfnClient := cloudformation.NewFromConfig(cfg)
+	eventbridgeClient := eventbridge.NewFromConfig(cfg)
+
+	results, err := apprunnerops.PauseOrResumeServices(ctx, apprunnerClient, cfnClient, event.StackName, event.Action)
+	if results == nil {
+		return nil, err
+	}
+
+	summary := &Summary{
+		StackName: event.StackName,
+		Action:    event.Action,
+		Results:   results,
+	}
+	if publishErr := publishSummary(ctx, eventbridgeClient, summary); publishErr != nil {
+		if err != nil {
+			return summary, fmt.Errorf("%v, and failed to publish summary: %v", err, publishErr)
+		}
+		return summary, publishErr
+	}
+
+	return summary, err
+}
+
+func publishSummary(ctx context.Context, client *eventbridge.Client, summary *Summary) error {
+	detail, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	output, err := client.PutEvents(ctx, &eventbridge.PutEventsInput{
+		Entries: []eventbridgeTypes.PutEventsRequestEntry{
+			{
+				Source:     aws.String(summaryEventSource),
+				DetailType: aws.String(summaryEventDetailType),
+				Detail:     aws.String(string(detail)),
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if output.FailedEntryCount > 0 {
+		return fmt.Errorf("PutEvents Error: %s", aws.ToString(output.Entries[0].ErrorMessage))
+	}
+
+	return nil
+}
+
+// The same binary works as a CLI command outside of Lambda:
+//
+//	go run ./custom/pauseresume -action pause -stack AppRunnerGoStack [-region ap-northeast-1] [-profile profile]
+func main() {
+	if os.Getenv("AWS_LAMBDA_RUNTIME_API") != "" {
+		lambda.Start(HandleRequest)
+		return
+	}
+
+	action := flag.String("action", "", "pause or resume")
+	stackName := flag.String("stack", "", "name of the stack of the App Runner services")
+	region := flag.String("region", "", "AWS region (default: resolved from the environment or the profile)")
+	profile := flag.String("profile", "", "AWS profile")
+	flag.Parse()
+
+	if *action == "" || *stackName == "" {
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(*region), config.WithSharedConfigProfile(*profile))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	summary, err := run(ctx, cfg, PauseResumeEvent{
+		Action:    *action,
+		StackName: *stackName,
+	})
+	if summary != nil {
+		for _, result := range summary.Results {
+			switch {
+			case result.Error != "":
+				fmt.Printf("%s: FAILED (%s)\n", result.ServiceArn, result.Error)
+			case result.Skipped:
+				fmt.Printf("%s: already %s, skipped\n", result.ServiceArn, result.Status)
+			default:
+				fmt.Printf("%s: %s\n", result.ServiceArn, result.Status)
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
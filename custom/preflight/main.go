@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/cfn"
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas"
+	"github.com/aws/aws-sdk-go-v2/service/servicequotas/types"
+)
+
+// Fargate On-Demand vCPU resource count
+const (
+	fargateServiceCode     = "fargate"
+	fargateVcpuQuotaCode   = "L-3032A538"
+	fargateVcpuQuotaDetail = "Fargate On-Demand vCPU resource count"
+)
+
+type InputProps struct {
+	requiredVcpu float64
+	breakdown    string
+}
+
+func HandleRequest(ctx context.Context, event cfn.Event) (physicalResourceID string, data map[string]interface{}, err error) {
+	physicalResourceID = "VcpuQuotaPreflight"
+	data = make(map[string]interface{})
+
+	if event.RequestType == "Delete" {
+		return
+	}
+
+	inputProps, err := convertInputParameters(event.ResourceProperties)
+	if err != nil {
+		return "", nil, err
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(os.Getenv("AWS_REGION")))
+	if err != nil {
+		return "", nil, err
+	}
+
+	quotaValue, err := getVcpuQuotaValue(ctx, servicequotas.NewFromConfig(cfg))
+	if err != nil {
+		return "", nil, err
+	}
+
+	if inputProps.requiredVcpu > quotaValue {
+		return "", nil, fmt.Errorf(
+			"Worst-case vCPU usage of the App Runner services is %g (%s), but the %s quota is %g. "+
+				"Request a quota increase of %s/%s in Service Quotas before deploying, otherwise the service update fails after long retries.",
+			inputProps.requiredVcpu, inputProps.breakdown,
+			fargateVcpuQuotaDetail, quotaValue,
+			fargateServiceCode, fargateVcpuQuotaCode,
+		)
+	}
+
+	data["QuotaValue"] = strconv.FormatFloat(quotaValue, 'f', -1, 64)
+
+	return
+}
+
+// getVcpuQuotaValue returns the applied quota value, or the AWS default value if the quota has never been changed.
+func getVcpuQuotaValue(ctx context.Context, client *servicequotas.Client) (float64, error) {
+	output, err := client.GetServiceQuota(ctx, &servicequotas.GetServiceQuotaInput{
+		ServiceCode: aws.String(fargateServiceCode),
+		QuotaCode:   aws.String(fargateVcpuQuotaCode),
+	})
+	if err == nil {
+		return aws.ToFloat64(output.Quota.Value), nil
+	}
+
+	var noSuchResourceException *types.NoSuchResourceException
+	if !errors.As(err, &noSuchResourceException) {
+		return 0, err
+	}
+
+	defaultOutput, err := client.GetAWSDefaultServiceQuota(ctx, &servicequotas.GetAWSDefaultServiceQuotaInput{
+		ServiceCode: aws.String(fargateServiceCode),
+		QuotaCode:   aws.String(fargateVcpuQuotaCode),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return aws.ToFloat64(defaultOutput.Quota.Value), nil
+}
+
+func convertInputParameters(resourceProperties map[string]interface{}) (*InputProps, error) {
+	requiredVcpuInput, ok := resourceProperties["RequiredVcpu"].(string)
+	if !ok {
+		return nil, fmt.Errorf("RequiredVcpu Assertion Error: %v", resourceProperties["RequiredVcpu"])
+	}
+	requiredVcpu, err := strconv.ParseFloat(requiredVcpuInput, 64)
+	if err != nil {
+		return nil, fmt.Errorf("RequiredVcpu Convert Error: %v", requiredVcpuInput)
+	}
+
+	breakdown, ok := resourceProperties["Breakdown"].(string)
+	if !ok {
+		return nil, fmt.Errorf("Breakdown Assertion Error: %v", resourceProperties["Breakdown"])
+	}
+
+	return &InputProps{
+		requiredVcpu: requiredVcpu,
+		breakdown:    breakdown,
+	}, nil
+}
+
+func main() {
+	lambda.Start(cfn.LambdaWrap(HandleRequest))
+}
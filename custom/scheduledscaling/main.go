@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"go-cdk-go-managed-apprunner/custom/apprunnerops"
+	"os"
+
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/apprunner"
+	"github.com/aws/aws-sdk-go-v2/service/cloudformation"
+)
+
+// ScheduledScalingEvent is the input of the EventBridge Scheduler schedule of a scaling profile.
+type ScheduledScalingEvent struct {
+	StackName                   string
+	ServiceArnExportNames       []string
+	AutoScalingConfigurationArn string
+}
+
+func HandleRequest(ctx context.Context, event ScheduledScalingEvent) error {
+	if event.StackName == "" || event.AutoScalingConfigurationArn == "" {
+		return fmt.Errorf("StackName and AutoScalingConfigurationArn are required: %+v", event)
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(os.Getenv("AWS_REGION")))
+	if err != nil {
+		return err
+	}
+
+	apprunnerClient := apprunner.NewFromConfig(cfg)
+	cfnClient := cloudformation.NewFromConfig(cfg)
+
+	return apprunnerops.UpdateServiceForAutoScalingConfiguration(
+		ctx,
+		apprunnerClient,
+		cfnClient,
+		event.StackName,
+		event.ServiceArnExportNames,
+		event.AutoScalingConfigurationArn,
+	)
+}
+
+func main() {
+	lambda.Start(HandleRequest)
+}